@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	name        = "diff"
+	description = "Show the differences between the rendered configuration of two directories or git revisions."
+)
+
+type Config struct {
+	Logger logr.Logger
+	Stderr io.Writer
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	f := &flag{}
+
+	r := &runner{
+		flag:   f,
+		logger: config.Logger,
+		stderr: config.Stderr,
+		stdout: config.Stdout,
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long: description + `
+
+The schema is rendered with the same variables against --dir at --ref and against
+--other-dir at --other-ref. Directories default to --dir and revisions to the
+working tree. A schema inside --dir is read from the same relative path on both
+sides, so changes to the schema itself are part of the diff.`,
+		Args: cobra.NoArgs,
+		RunE: r.Run,
+	}
+
+	f.Init(c)
+
+	return c, nil
+}
@@ -0,0 +1,31 @@
+package diff
+
+import (
+	"reflect"
+)
+
+type InvalidFlagError struct {
+	message string
+}
+
+func (e *InvalidFlagError) Error() string {
+	return "InvalidFlagError: " + e.message
+}
+
+func (e *InvalidFlagError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
+
+// DifferencesFoundError is returned with --exit-code when the rendered
+// configurations differ.
+type DifferencesFoundError struct {
+	message string
+}
+
+func (e *DifferencesFoundError) Error() string {
+	return "DifferencesFoundError: " + e.message
+}
+
+func (e *DifferencesFoundError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
@@ -0,0 +1,76 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv/key"
+)
+
+const (
+	flagSchema         = "schema"
+	flagDir            = "dir"
+	flagRef            = "ref"
+	flagOtherDir       = "other-dir"
+	flagOtherRef       = "other-ref"
+	flagSOPSKeysSource = "sops-keys-source"
+	flagSOPSKeysDir    = "sops-keys-dir"
+	flagVariable       = "variable"
+	flagShowSecrets    = "show-secrets"
+	flagExitCode       = "exit-code"
+	flagFormat         = "format"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+)
+
+type flag struct {
+	Schema         string
+	Dir            string
+	Ref            string
+	OtherDir       string
+	OtherRef       string
+	SOPSKeysDir    string
+	SOPSKeysSource string
+	Variables      []string
+	ShowSecrets    bool
+	ExitCode       bool
+	Format         string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().StringVar(&f.Dir, flagDir, ".", `Directory containing configuration source (e.g cloned "giantswarm/config" repo).`)
+	cmd.Flags().StringVar(&f.Ref, flagRef, "", `Git revision of --dir to compare from, defaults to the working tree (optional).`)
+	cmd.Flags().StringVar(&f.OtherDir, flagOtherDir, "", `Directory containing the configuration source to compare to, defaults to --dir (optional).`)
+	cmd.Flags().StringVar(&f.OtherRef, flagOtherRef, "", `Git revision of --other-dir to compare to, defaults to the working tree (optional).`)
+	cmd.Flags().StringVar(&f.SOPSKeysDir, flagSOPSKeysDir, "", `Directory containing SOPS private keys (optional).`)
+	cmd.Flags().StringVar(&f.SOPSKeysSource, flagSOPSKeysSource, "local", `Source of SOPS private keys, supports "local" and "kubernetes", (optional).`)
+	cmd.Flags().StringArrayVar(&f.Variables, flagVariable, []string{}, `Variables for rendering the schema.`)
+	cmd.Flags().BoolVar(&f.ShowSecrets, flagShowSecrets, false, `Show secret values in the diff instead of redacting them.`)
+	cmd.Flags().BoolVar(&f.ExitCode, flagExitCode, false, `Exit with a non-zero exit code when the rendered configurations differ.`)
+	cmd.Flags().StringVar(&f.Format, flagFormat, formatText, `Format of the diff, supports "json" and "text".`)
+}
+
+func (f *flag) Validate() error {
+	if f.Schema == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSchema)}
+	}
+	if f.Dir == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagDir)}
+	}
+	if f.OtherDir == "" && f.Ref == "" && f.OtherRef == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("at least one of --%s, --%s or --%s must be set", flagOtherDir, flagRef, flagOtherRef)}
+	}
+	if f.SOPSKeysSource != key.KeysSourceLocal && f.SOPSKeysSource != key.KeysSourceKubernetes {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagSOPSKeysSource, "local,kubernetes")}
+	}
+	if f.Format != formatJSON && f.Format != formatText {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagFormat, "json,text")}
+	}
+
+	return nil
+}
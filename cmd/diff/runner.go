@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/diff"
+	"github.com/giantswarm/konfigure/v2/pkg/filesystem"
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// result holds the changes of the rendered ConfigMap and Secret.
+type result struct {
+	ConfigMap []diff.Change `json:"configMap"`
+	Secret    []diff.Change `json:"secret"`
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	// Setup SOPS environment
+	sopsEnv, err := sopsenv.NewSOPSEnv(sopsenv.SOPSEnvConfig{
+		KeysDir:    r.flag.SOPSKeysDir,
+		KeysSource: r.flag.SOPSKeysSource,
+		Logger:     r.logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = sopsEnv.Setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer sopsEnv.Cleanup()
+
+	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+		Log: r.logger,
+	})
+
+	otherDir := r.flag.OtherDir
+	if otherDir == "" {
+		otherDir = r.flag.Dir
+	}
+
+	fromConfigMap, fromSecret, err := r.render(dynamicService, r.flag.Dir, r.flag.Ref)
+	if err != nil {
+		return err
+	}
+
+	toConfigMap, toSecret, err := r.render(dynamicService, otherDir, r.flag.OtherRef)
+	if err != nil {
+		return err
+	}
+
+	var res result
+
+	res.ConfigMap, err = diff.YAML(fromConfigMap, toConfigMap)
+	if err != nil {
+		return err
+	}
+
+	res.Secret, err = diff.YAML(fromSecret, toSecret)
+	if err != nil {
+		return err
+	}
+
+	if !r.flag.ShowSecrets {
+		res.Secret = diff.Redact(res.Secret)
+	}
+
+	if r.flag.Format == formatJSON {
+		err = printJSON(r.stdout, res)
+	} else {
+		err = printText(r.stdout, res)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.flag.ExitCode && len(res.ConfigMap)+len(res.Secret) > 0 {
+		return &DifferencesFoundError{message: fmt.Sprintf("%d ConfigMap and %d Secret changes found", len(res.ConfigMap), len(res.Secret))}
+	}
+
+	return nil
+}
+
+// render renders the schema against the directory at the git revision, or its
+// working tree if the revision is empty.
+func (r *runner) render(dynamicService *service.DynamicService, dir, revision string) (configMapData, secretData string, err error) {
+	relativeSchema, inDir, err := r.schemaIn()
+	if err != nil {
+		return "", "", err
+	}
+
+	if revision == "" {
+		schema := r.flag.Schema
+		if inDir {
+			schema = filepath.Join(dir, relativeSchema)
+		}
+
+		return dynamicService.RenderRaw(dir, schema, r.flag.Variables)
+	}
+
+	store := &filesystem.Store{Dir: dir}
+
+	revisionFS, err := store.RevisionFS(revision)
+	if err != nil {
+		return "", "", err
+	}
+
+	// A schema outside of --dir is not part of the revision and read as is.
+	if !inDir {
+		return dynamicService.RenderRawFiles(revisionFS, r.flag.Schema, r.flag.Variables)
+	}
+
+	return dynamicService.RenderRawFS(revisionFS, filepath.ToSlash(relativeSchema), r.flag.Variables)
+}
+
+// schemaIn returns the path of the schema relative to --dir and whether the
+// schema is inside --dir at all.
+func (r *runner) schemaIn() (string, bool, error) {
+	absSchema, err := filepath.Abs(r.flag.Schema)
+	if err != nil {
+		return "", false, err
+	}
+
+	absDir, err := filepath.Abs(r.flag.Dir)
+	if err != nil {
+		return "", false, err
+	}
+
+	relativeSchema, err := filepath.Rel(absDir, absSchema)
+	if err != nil || relativeSchema == ".." || strings.HasPrefix(relativeSchema, ".."+string(filepath.Separator)) {
+		return "", false, nil
+	}
+
+	return relativeSchema, true, nil
+}
+
+func printJSON(w io.Writer, res result) error {
+	if res.ConfigMap == nil {
+		res.ConfigMap = []diff.Change{}
+	}
+	if res.Secret == nil {
+		res.Secret = []diff.Change{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(res)
+}
+
+func printText(w io.Writer, res result) error {
+	if len(res.ConfigMap)+len(res.Secret) == 0 {
+		_, err := fmt.Fprintln(w, "No differences found.")
+		return err
+	}
+
+	sections := []struct {
+		title   string
+		changes []diff.Change
+	}{
+		{title: "ConfigMap", changes: res.ConfigMap},
+		{title: "Secret", changes: res.Secret},
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			_, err := fmt.Fprintln(w)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%s:\n", section.title)
+		if err != nil {
+			return err
+		}
+
+		if len(section.changes) == 0 {
+			_, err = fmt.Fprintln(w, "  no differences")
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, change := range section.changes {
+			_, err = fmt.Fprintf(w, "  %s\n", change.Format())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package explain
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	name        = "explain <path>"
+	description = "Explain which layers, files and operations set a value of the rendered configuration."
+)
+
+type Config struct {
+	Logger logr.Logger
+	Stderr io.Writer
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	f := &flag{}
+
+	r := &runner{
+		flag:   f,
+		logger: config.Logger,
+		stderr: config.Stderr,
+		stdout: config.Stdout,
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long: description + `
+
+The path is either dot separated, e.g. "apps.ingress.hosts[0]", or a JSON pointer,
+e.g. "/apps/ingress/hosts/0".`,
+		Args: cobra.ExactArgs(1),
+		RunE: r.Run,
+	}
+
+	f.Init(c)
+
+	return c, nil
+}
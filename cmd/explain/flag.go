@@ -0,0 +1,67 @@
+package explain
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv/key"
+)
+
+const (
+	flagSchema         = "schema"
+	flagDir            = "dir"
+	flagSOPSKeysSource = "sops-keys-source"
+	flagSOPSKeysDir    = "sops-keys-dir"
+	flagVariable       = "variable"
+	flagSecret         = "secret"
+	flagShowSecrets    = "show-secrets"
+	flagFormat         = "format"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+)
+
+type flag struct {
+	Schema         string
+	Dir            string
+	SOPSKeysDir    string
+	SOPSKeysSource string
+	Variables      []string
+	Secret         bool
+	ShowSecrets    bool
+	Format         string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().StringVar(&f.Dir, flagDir, ".", `Directory containing configuration source (e.g cloned "giantswarm/config" repo).`)
+	cmd.Flags().StringVar(&f.SOPSKeysDir, flagSOPSKeysDir, "", `Directory containing SOPS private keys (optional).`)
+	cmd.Flags().StringVar(&f.SOPSKeysSource, flagSOPSKeysSource, "local", `Source of SOPS private keys, supports "local" and "kubernetes", (optional).`)
+	cmd.Flags().StringArrayVar(&f.Variables, flagVariable, []string{}, `Variables for rendering the schema.`)
+	cmd.Flags().BoolVar(&f.Secret, flagSecret, false, `Explain the path in the rendered Secret instead of the ConfigMap, secret values are redacted unless --show-secrets is set.`)
+	cmd.Flags().BoolVar(&f.ShowSecrets, flagShowSecrets, false, `Show secret values in the explanation instead of redacting them, requires --secret.`)
+	cmd.Flags().StringVar(&f.Format, flagFormat, formatText, `Format of the explanation, supports "json" and "text".`)
+}
+
+func (f *flag) Validate() error {
+	if f.Schema == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSchema)}
+	}
+	if f.Dir == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagDir)}
+	}
+	if f.SOPSKeysSource != key.KeysSourceLocal && f.SOPSKeysSource != key.KeysSourceKubernetes {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagSOPSKeysSource, "local,kubernetes")}
+	}
+	if f.ShowSecrets && !f.Secret {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s requires --%s", flagShowSecrets, flagSecret)}
+	}
+	if f.Format != formatJSON && f.Format != formatText {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagFormat, "json,text")}
+	}
+
+	return nil
+}
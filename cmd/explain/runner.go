@@ -0,0 +1,168 @@
+package explain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv"
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	// Setup SOPS environment
+	sopsEnv, err := sopsenv.NewSOPSEnv(sopsenv.SOPSEnvConfig{
+		KeysDir:    r.flag.SOPSKeysDir,
+		KeysSource: r.flag.SOPSKeysSource,
+		Logger:     r.logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = sopsEnv.Setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer sopsEnv.Cleanup()
+
+	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+		Log: r.logger,
+	})
+
+	valueType := model.ValueMergeReferenceTypeConfigMap
+	if r.flag.Secret {
+		valueType = model.ValueMergeReferenceTypeSecret
+	}
+
+	explanation, err := dynamicService.Explain(r.flag.Dir, r.flag.Schema, r.flag.Variables, valueType, args[0])
+	if err != nil {
+		return err
+	}
+
+	if r.flag.Secret && !r.flag.ShowSecrets {
+		explanation = explanation.Redact()
+	}
+
+	if r.flag.Format == formatJSON {
+		encoder := json.NewEncoder(r.stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(explanation)
+	}
+
+	return printExplanation(r.stdout, explanation)
+}
+
+func printExplanation(w io.Writer, explanation *renderer.Explanation) error {
+	if explanation.Found {
+		_, err := fmt.Fprintf(w, "%s %s = %s\n", explanation.Type, explanation.Path, formatValue(explanation.Value, false))
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := fmt.Fprintf(w, "%s %s is not set\n", explanation.Type, explanation.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if step, found := explanation.LastWrite(); found {
+		_, err := fmt.Fprintf(w, "last written by %s of layer %s in %s\n", describeStep(step), step.Layer, formatFile(step.File))
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(explanation.Steps) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(w)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err = fmt.Fprintln(tw, "#\tLAYER\tOPERATION\tFILE\tVALUE")
+	if err != nil {
+		return err
+	}
+
+	for i, step := range explanation.Steps {
+		_, err = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, step.Layer, describeStep(step), formatFile(step.File), formatValue(step.Value, step.Removed))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func describeStep(step renderer.ExplanationStep) string {
+	if step.Operation == renderer.ExplanationOperationPatch && step.Detail != "" {
+		return fmt.Sprintf("%s (%s)", step.Operation, step.Detail)
+	}
+
+	return step.Operation
+}
+
+func formatFile(file string) string {
+	if file == "" {
+		return "-"
+	}
+
+	return file
+}
+
+func formatValue(value interface{}, removed bool) string {
+	if removed {
+		return "<removed>"
+	}
+
+	if value == utils.RedactedValue {
+		return utils.RedactedValue
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(encoded)
+}
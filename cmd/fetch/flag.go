@@ -0,0 +1,63 @@
+package fetch
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/fluxupdater"
+)
+
+const (
+	flagGitRepository = "git-repository"
+	flagOCIRepository = "oci-repository"
+	flagBucket        = "bucket"
+	flagCacheDir      = "cache-dir"
+	flagKeepRevisions = "keep-revisions"
+)
+
+type flag struct {
+	GitRepository string
+	OCIRepository string
+	Bucket        string
+	CacheDir      string
+	KeepRevisions int
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.GitRepository, flagGitRepository, "", `Flux GitRepository to fetch the artifact of, in the format of <namespace>/<name>.`)
+	cmd.Flags().StringVar(&f.OCIRepository, flagOCIRepository, "", `Flux OCIRepository to fetch the artifact of, in the format of <namespace>/<name>.`)
+	cmd.Flags().StringVar(&f.Bucket, flagBucket, "", `Flux Bucket to fetch the artifact of, in the format of <namespace>/<name>.`)
+	cmd.Flags().StringVar(&f.CacheDir, flagCacheDir, "", `Directory to cache the artifact in, defaults to a directory of the source in the user cache directory (optional).`)
+	cmd.Flags().IntVar(&f.KeepRevisions, flagKeepRevisions, fluxupdater.DefaultKeepRevisions, `Number of previous revisions of the artifact kept in the cache directory for rollback.`)
+}
+
+func (f *flag) Validate() error {
+	sources := 0
+	for _, source := range []string{f.GitRepository, f.OCIRepository, f.Bucket} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return &InvalidFlagError{message: fmt.Sprintf("exactly one of --%s, --%s and --%s must be set", flagGitRepository, flagOCIRepository, flagBucket)}
+	}
+
+	if f.KeepRevisions < 0 {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be negative", flagKeepRevisions)}
+	}
+
+	return nil
+}
+
+// source returns the kind and the reference of the Flux source to fetch.
+func (f *flag) source() (kind, source string) {
+	switch {
+	case f.OCIRepository != "":
+		return fluxupdater.SourceKindOCIRepository, f.OCIRepository
+	case f.Bucket != "":
+		return fluxupdater.SourceKindBucket, f.Bucket
+	default:
+		return fluxupdater.SourceKindGitRepository, f.GitRepository
+	}
+}
@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/fluxupdater"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(_ context.Context, _ *cobra.Command, _ []string) error {
+	sourceKind, source := r.flag.source()
+
+	cacheDir := r.flag.CacheDir
+	if cacheDir == "" {
+		var err error
+
+		cacheDir, err = fluxupdater.DefaultCacheDir(sourceKind, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	fluxUpdater, err := fluxupdater.New(fluxupdater.Config{
+		CacheDir:      cacheDir,
+		KeepRevisions: r.flag.KeepRevisions,
+		SourceKind:    sourceKind,
+		Source:        source,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Fetching source artifact...", "kind", sourceKind, "source", source, "cacheDir", cacheDir)
+
+	err = fluxUpdater.UpdateConfig()
+	if err != nil {
+		return err
+	}
+
+	// Print the directory of the artifact, so it can be passed to --dir.
+	_, err = fmt.Fprintln(r.stdout, fluxUpdater.LatestDir())
+	return err
+}
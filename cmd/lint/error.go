@@ -0,0 +1,29 @@
+package lint
+
+import (
+	"reflect"
+)
+
+type InvalidFlagError struct {
+	message string
+}
+
+func (e *InvalidFlagError) Error() string {
+	return "InvalidFlagError: " + e.message
+}
+
+func (e *InvalidFlagError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
+
+type InvalidSchemaError struct {
+	message string
+}
+
+func (e *InvalidSchemaError) Error() string {
+	return "InvalidSchemaError: " + e.message
+}
+
+func (e *InvalidSchemaError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
@@ -0,0 +1,38 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagSchema = "schema"
+	flagFormat = "format"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+)
+
+type flag struct {
+	Schema string
+	Format string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().StringVar(&f.Format, flagFormat, formatJSON, `Format of the report, supports "json" and "text".`)
+}
+
+func (f *flag) Validate() error {
+	if f.Schema == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSchema)}
+	}
+	if f.Format != formatJSON && f.Format != formatText {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagFormat, "json,text")}
+	}
+
+	return nil
+}
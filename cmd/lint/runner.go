@@ -0,0 +1,81 @@
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/lint"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	schema, err := renderer.LoadSchema(r.flag.Schema)
+	if err != nil {
+		return err
+	}
+
+	report := lint.Report{
+		Schema:   r.flag.Schema,
+		Problems: lint.Lint(schema),
+		Warnings: lint.Warnings(schema),
+	}
+
+	switch r.flag.Format {
+	case formatText:
+		for _, problem := range report.Problems {
+			_, err = fmt.Fprintf(r.stdout, "%s: %s: %s\n", report.Schema, problem.Path, problem.Message)
+			if err != nil {
+				return err
+			}
+		}
+		for _, warning := range report.Warnings {
+			_, err = fmt.Fprintf(r.stdout, "%s: %s: warning: %s\n", report.Schema, warning.Path, warning.Message)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		encoder := json.NewEncoder(r.stdout)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(report)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(report.Problems) > 0 {
+		return &InvalidSchemaError{message: fmt.Sprintf("found %d problem(s) in schema %q", len(report.Problems), r.flag.Schema)}
+	}
+
+	return nil
+}
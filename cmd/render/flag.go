@@ -2,7 +2,9 @@ package render
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/giantswarm/konfigure/v2/pkg/fluxupdater"
 	"github.com/giantswarm/konfigure/v2/pkg/model"
 
 	"github.com/spf13/cobra"
@@ -11,41 +13,77 @@ import (
 )
 
 const (
-	flagSchema           = "schema"
-	flagDir              = "dir"
-	flagSOPSKeysSource   = "sops-keys-source"
-	flagSOPSKeysDir      = "sops-keys-dir"
-	flagVerbose          = "verbose"
-	flagVariable         = "variable"
-	flagRaw              = "raw"
-	flagName             = "name"
-	flagNamespace        = "namespace"
-	flagConfigMapDataKey = "config-map-data-key"
-	flagSecretDataKey    = "secret-data-key"
+	flagSchema            = "schema"
+	flagDir               = "dir"
+	flagRevision          = "revision"
+	flagFromGitRepository = "from-gitrepository"
+	flagFromOCIRepository = "from-ocirepository"
+	flagFromBucket        = "from-bucket"
+	flagCacheDir          = "cache-dir"
+	flagKeepRevisions     = "keep-revisions"
+	flagSOPSKeysSource    = "sops-keys-source"
+	flagSOPSKeysDir       = "sops-keys-dir"
+	flagVerbose           = "verbose"
+	flagWatch             = "watch"
+	flagWatchInterval     = "watch-interval"
+	flagWatchDiff         = "watch-diff"
+	flagShowSecrets       = "show-secrets"
+	flagVariable          = "variable"
+	flagRaw               = "raw"
+	flagOutput            = "output"
+	flagOutputDir         = "output-dir"
+	flagName              = "name"
+	flagNamespace         = "namespace"
+	flagConfigMapDataKey  = "config-map-data-key"
+	flagSecretDataKey     = "secret-data-key"
 )
 
 type flag struct {
-	Schema           string
-	Dir              string
-	SOPSKeysDir      string
-	SOPSKeysSource   string
-	Verbose          bool
-	Variables        []string
-	Raw              bool
-	Name             string
-	Namespace        string
-	ConfigMapDataKey string
-	SecretDataKey    string
+	Schema            string
+	Dir               string
+	Revision          string
+	FromGitRepository string
+	FromOCIRepository string
+	FromBucket        string
+	CacheDir          string
+	KeepRevisions     int
+	SOPSKeysDir       string
+	SOPSKeysSource    string
+	Verbose           bool
+	Watch             bool
+	WatchInterval     time.Duration
+	WatchDiff         bool
+	ShowSecrets       bool
+	Variables         []string
+	Raw               bool
+	Output            string
+	OutputDir         string
+	Name              string
+	Namespace         string
+	ConfigMapDataKey  string
+	SecretDataKey     string
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
-	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file, relative paths are read from the artifact when rendering a Flux source.`)
 	cmd.Flags().StringVar(&f.Dir, flagDir, ".", `Directory containing configuration source (e.g cloned "giantswarm/config" repo).`)
+	cmd.Flags().StringVar(&f.Revision, flagRevision, "", `Git revision of --dir to render, e.g. a tag or commit, instead of its working tree (optional).`)
+	cmd.Flags().StringVar(&f.FromGitRepository, flagFromGitRepository, "", `Flux GitRepository in the format of <namespace>/<name> to fetch and render the artifact of instead of --dir (optional).`)
+	cmd.Flags().StringVar(&f.FromOCIRepository, flagFromOCIRepository, "", `Flux OCIRepository in the format of <namespace>/<name> to fetch and render the artifact of instead of --dir (optional).`)
+	cmd.Flags().StringVar(&f.FromBucket, flagFromBucket, "", `Flux Bucket in the format of <namespace>/<name> to fetch and render the artifact of instead of --dir (optional).`)
+	cmd.Flags().StringVar(&f.CacheDir, flagCacheDir, "", `Directory to cache the artifact of the Flux source in, defaults to a directory of the source in the user cache directory (optional).`)
+	cmd.Flags().IntVar(&f.KeepRevisions, flagKeepRevisions, fluxupdater.DefaultKeepRevisions, `Number of previous revisions of the artifact of the Flux source kept in the cache directory for rollback.`)
 	cmd.Flags().StringVar(&f.SOPSKeysDir, flagSOPSKeysDir, "", `Directory containing SOPS private keys (optional).`)
 	cmd.Flags().StringVar(&f.SOPSKeysSource, flagSOPSKeysSource, "local", `Source of SOPS private keys, supports "local" and "kubernetes", (optional).`)
 	cmd.Flags().BoolVar(&f.Verbose, flagVerbose, false, `Enables generator to output consecutive generation stages.`)
+	cmd.Flags().BoolVar(&f.Watch, flagWatch, false, `Render again whenever a file used by the render changes, or a new artifact of the Flux source arrives, until interrupted.`)
+	cmd.Flags().DurationVar(&f.WatchInterval, flagWatchInterval, time.Minute, `Interval to poll the Flux source for new artifacts at in watch mode.`)
+	cmd.Flags().BoolVar(&f.WatchDiff, flagWatchDiff, false, `Print the changes since the previous render instead of the whole output in watch mode, secret values are redacted unless --show-secrets is set.`)
+	cmd.Flags().BoolVar(&f.ShowSecrets, flagShowSecrets, false, `Show secret values in the generation stages or the changes in watch mode instead of redacting them, requires --verbose or --watch-diff.`)
 	cmd.Flags().StringArrayVar(&f.Variables, flagVariable, []string{}, `Variables for rendering the schema.`)
 	cmd.Flags().BoolVar(&f.Raw, flagRaw, false, `Forces generator to output YAML instead of ConfigMap & Secret.`)
+	cmd.Flags().StringVar(&f.Output, flagOutput, "", `File to write the rendered ConfigMap and Secret, or raw YAML, to instead of stdout (optional).`)
+	cmd.Flags().StringVar(&f.OutputDir, flagOutputDir, "", `Directory to write the rendered ConfigMap and Secret, or raw YAML, to as separate files instead of stdout (optional).`)
 	cmd.Flags().StringVar(&f.Name, flagName, "", `Name of the rendered config map and secret.`)
 	cmd.Flags().StringVar(&f.Namespace, flagNamespace, "default", `Namespace of the rendered config map and secret.`)
 	cmd.Flags().StringVar(&f.ConfigMapDataKey, flagConfigMapDataKey, model.DefaultConfigMapDataKey, `The key to store the rendered data in the generated ConfigMap.`)
@@ -59,9 +97,42 @@ func (f *flag) Validate() error {
 	if f.Dir == "" {
 		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagDir)}
 	}
+	sources := 0
+	for _, source := range []string{f.FromGitRepository, f.FromOCIRepository, f.FromBucket} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s, --%s and --%s are mutually exclusive", flagFromGitRepository, flagFromOCIRepository, flagFromBucket)}
+	}
+	if sources > 0 && f.Revision != "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s cannot be used with a Flux source", flagRevision)}
+	}
+	if f.KeepRevisions < 0 {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be negative", flagKeepRevisions)}
+	}
 	if f.SOPSKeysSource != key.KeysSourceLocal && f.SOPSKeysSource != key.KeysSourceKubernetes {
 		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagSOPSKeysSource, "local,kubernetes")}
 	}
+	if f.ShowSecrets && !f.Verbose && !f.WatchDiff {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s requires --%s or --%s", flagShowSecrets, flagVerbose, flagWatchDiff)}
+	}
+	if f.WatchDiff && !f.Watch {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s requires --%s", flagWatchDiff, flagWatch)}
+	}
+	if f.Watch && f.Revision != "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s cannot be used with --%s, revisions do not change", flagWatch, flagRevision)}
+	}
+	if f.Watch && f.WatchInterval <= 0 {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be positive", flagWatchInterval)}
+	}
+	if f.Output != "" && f.OutputDir != "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s and --%s are mutually exclusive", flagOutput, flagOutputDir)}
+	}
+	if f.Watch && (f.Output != "" || f.OutputDir != "") {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s and --%s cannot be used with --%s", flagOutput, flagOutputDir, flagWatch)}
+	}
 	if f.Name == "" && !f.Raw {
 		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagName)}
 	}
@@ -77,3 +148,18 @@ func (f *flag) Validate() error {
 
 	return nil
 }
+
+// source returns the kind and the reference of the Flux source to render, or
+// empty strings if --dir is rendered.
+func (f *flag) source() (kind, source string) {
+	switch {
+	case f.FromGitRepository != "":
+		return fluxupdater.SourceKindGitRepository, f.FromGitRepository
+	case f.FromOCIRepository != "":
+		return fluxupdater.SourceKindOCIRepository, f.FromOCIRepository
+	case f.FromBucket != "":
+		return fluxupdater.SourceKindBucket, f.FromBucket
+	default:
+		return "", ""
+	}
+}
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+// Names of the files written to --output-dir.
+const (
+	outputConfigMapFile     = "configmap.yaml"
+	outputSecretFile        = "secret.yaml"
+	outputConfigMapDataFile = "configmap-values.yaml"
+	outputSecretDataFile    = "secret-values.yaml"
+)
+
+// document is a YAML document of the output, written to its own file in
+// --output-dir.
+type document struct {
+	fileName string
+	write    func(w io.Writer) error
+}
+
+// wrappedDocuments returns the documents of the rendered ConfigMap and Secret.
+func wrappedDocuments(configMap *corev1.ConfigMap, secret *corev1.Secret) []document {
+	return []document{
+		{
+			fileName: outputConfigMapFile,
+			write:    func(w io.Writer) error { return utils.PrettyPrint(w, configMap) },
+		},
+		{
+			fileName: outputSecretFile,
+			write:    func(w io.Writer) error { return utils.PrettyPrint(w, secret) },
+		},
+	}
+}
+
+// rawDocuments returns the documents of the rendered data with --raw.
+func rawDocuments(configMapData, secretData string) []document {
+	writeData := func(data string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "---\n%s\n", data)
+			return err
+		}
+	}
+
+	return []document{
+		{fileName: outputConfigMapDataFile, write: writeData(configMapData)},
+		{fileName: outputSecretDataFile, write: writeData(secretData)},
+	}
+}
+
+// writeDocuments prints the documents to stdout, or writes them to the file of
+// --output, or to a file each in --output-dir.
+func (r *runner) writeDocuments(documents []document) error {
+	switch {
+	case r.flag.OutputDir != "":
+		for _, d := range documents {
+			var out bytes.Buffer
+
+			err := d.write(&out)
+			if err != nil {
+				return err
+			}
+
+			err = r.writeOutputFile(filepath.Join(r.flag.OutputDir, d.fileName), out.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+	case r.flag.Output != "":
+		var out bytes.Buffer
+
+		for _, d := range documents {
+			err := d.write(&out)
+			if err != nil {
+				return err
+			}
+		}
+
+		return r.writeOutputFile(r.flag.Output, out.Bytes())
+	default:
+		for _, d := range documents {
+			err := d.write(r.stdout)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeOutputFile writes the output to the file, readable by the user only as
+// it holds the decrypted secrets.
+func (r *runner) writeOutputFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755) // nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Output written", "path", path)
+
+	return nil
+}
@@ -4,15 +4,22 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/giantswarm/konfigure/v2/pkg/filesystem"
+	"github.com/giantswarm/konfigure/v2/pkg/fluxupdater"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
 
 	"github.com/giantswarm/konfigure/v2/pkg/sopsenv"
 
 	"github.com/giantswarm/konfigure/v2/pkg/service"
-	"github.com/giantswarm/konfigure/v2/pkg/utils"
 
 	"github.com/go-logr/logr"
 
 	"github.com/spf13/cobra"
+	yaml3 "gopkg.in/yaml.v3"
 )
 
 type runner struct {
@@ -57,46 +64,177 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 	defer sopsEnv.Cleanup()
 
 	// Setup dynamic service
-	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+	dynamicServiceConfig := service.DynamicServiceConfig{
 		Log: r.logger,
-	})
+	}
 
-	// Render configs
-	if r.flag.Raw {
-		configMapData, secretData, err := dynamicService.RenderRaw(r.flag.Dir, r.flag.Schema, r.flag.Variables)
+	if r.flag.Verbose {
+		dynamicServiceConfig.StageObserver = r.printStage
+		dynamicServiceConfig.ShowSecretStages = r.flag.ShowSecrets
+	}
+
+	// Renders in watch mode only decrypt and parse changed files
+	if r.flag.Watch {
+		dynamicServiceConfig.Cache = renderer.NewCache()
+	}
+
+	dynamicService := service.NewDynamicService(dynamicServiceConfig)
+
+	if r.flag.Watch {
+		return r.watch(ctx, dynamicService)
+	}
+
+	// Render the artifact Flux serves instead of --dir
+	dir := r.flag.Dir
+	schema := r.flag.Schema
+
+	if sourceKind, source := r.flag.source(); source != "" {
+		dir, err = r.fetchSource(sourceKind, source)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("---")
-		fmt.Println(configMapData)
-		fmt.Println("---")
-		fmt.Println(secretData)
-	} else {
-		configMap, secret, err := dynamicService.Render(service.RenderInput{
-			// Root directory of the config repository.
-			Dir:              r.flag.Dir,
-			Schema:           r.flag.Schema,
-			Variables:        r.flag.Variables,
-			Name:             r.flag.Name,
-			Namespace:        r.flag.Namespace,
-			ConfigMapDataKey: r.flag.ConfigMapDataKey,
-			SecretDataKey:    r.flag.SecretDataKey,
-		})
+		schema = r.sourceSchema(dir)
+	}
+
+	// Read the config repository from git when rendering a revision
+	var revisionFS fs.FS
+
+	if r.flag.Revision != "" {
+		revisionFS, schema, err = r.revisionSource()
 		if err != nil {
 			return err
 		}
+	}
 
-		err = utils.PrettyPrint(configMap)
+	// Render configs
+	if r.flag.Raw {
+		var configMapData, secretData string
+
+		if revisionFS != nil {
+			configMapData, secretData, err = dynamicService.RenderRawFS(revisionFS, schema, r.flag.Variables)
+		} else {
+			configMapData, secretData, err = dynamicService.RenderRaw(dir, schema, r.flag.Variables)
+		}
 		if err != nil {
 			return err
 		}
 
-		err = utils.PrettyPrint(secret)
+		return r.writeDocuments(rawDocuments(configMapData, secretData))
+	}
+
+	configMap, secret, err := dynamicService.Render(service.RenderInput{
+		// Root directory of the config repository.
+		Dir:              dir,
+		FS:               revisionFS,
+		Schema:           schema,
+		Variables:        r.flag.Variables,
+		Name:             r.flag.Name,
+		Namespace:        r.flag.Namespace,
+		ConfigMapDataKey: r.flag.ConfigMapDataKey,
+		SecretDataKey:    r.flag.SecretDataKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.writeDocuments(wrappedDocuments(configMap, secret))
+}
+
+// revisionSource returns the files of --dir at --revision and the path of the
+// schema in them. The schema is read from the revision as well, so it must be
+// inside --dir.
+func (r *runner) revisionSource() (fs.FS, string, error) {
+	absSchema, err := filepath.Abs(r.flag.Schema)
+	if err != nil {
+		return nil, "", err
+	}
+
+	absDir, err := filepath.Abs(r.flag.Dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	relativeSchema, err := filepath.Rel(absDir, absSchema)
+	if err != nil || relativeSchema == ".." || strings.HasPrefix(relativeSchema, ".."+string(filepath.Separator)) {
+		return nil, "", &InvalidFlagError{message: fmt.Sprintf("--%s must be inside --%s when --%s is set", flagSchema, flagDir, flagRevision)}
+	}
+
+	store := &filesystem.Store{Dir: r.flag.Dir}
+
+	revisionFS, err := store.RevisionFS(r.flag.Revision)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.logger.Info("Rendering revision...", "revision", r.flag.Revision, "commit", revisionFS.Revision())
+
+	return revisionFS, filepath.ToSlash(relativeSchema), nil
+}
+
+// fetchSource updates the artifact of the Flux source in the cache directory
+// and returns the directory it is unpacked to.
+func (r *runner) fetchSource(sourceKind, source string) (string, error) {
+	fluxUpdater, err := r.newFluxUpdater(sourceKind, source)
+	if err != nil {
+		return "", err
+	}
+
+	r.logger.Info("Fetching source artifact...", "kind", sourceKind, "source", source, "cacheDir", fluxUpdater.CacheDir)
+
+	err = fluxUpdater.UpdateConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return fluxUpdater.LatestDir(), nil
+}
+
+// sourceSchema returns the path of the schema to render the artifact of a Flux
+// source unpacked to dir with. A relative --schema is read from the artifact,
+// like the schema of the Kustomization rendering it in-cluster, an absolute
+// one from the local filesystem.
+func (r *runner) sourceSchema(dir string) string {
+	if filepath.IsAbs(r.flag.Schema) {
+		return r.flag.Schema
+	}
+
+	return filepath.Join(dir, r.flag.Schema)
+}
+
+func (r *runner) newFluxUpdater(sourceKind, source string) (*fluxupdater.FluxUpdater, error) {
+	cacheDir := r.flag.CacheDir
+	if cacheDir == "" {
+		var err error
+
+		cacheDir, err = fluxupdater.DefaultCacheDir(sourceKind, source)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return fluxupdater.New(fluxupdater.Config{
+		CacheDir:      cacheDir,
+		KeepRevisions: r.flag.KeepRevisions,
+		SourceKind:    sourceKind,
+		Source:        source,
+	})
+}
+
+// printStage prints the stage as a separate YAML document.
+func (r *runner) printStage(stage service.Stage) error {
+	_, err := fmt.Fprintln(r.stdout, "---")
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml3.NewEncoder(r.stdout)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(stage)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
 }
@@ -0,0 +1,190 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+// The test data of the dynamic service is rendered, see pkg/service/testdata.
+const stagesDir = "../../pkg/service/testdata/stages"
+
+func TestRunner_output(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, "config")
+
+	fs := testutils.NewMockFilesystem(configDir, stagesDir+"/cases/case9.yaml")
+
+	args := []string{
+		"--schema", stagesDir + "/schema.yaml",
+		"--dir", configDir,
+		"--variable", "stage=dev",
+		"--variable", "management-cluster=mc-1",
+		"--variable", "konfiguration=konfiguration-1",
+		"--name", "app-konfiguration",
+	}
+
+	// The output printed to stdout is the reference for the output files.
+	stdout, err := runRender(args...)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+	if !strings.Contains(stdout, "kind: ConfigMap") || !strings.Contains(stdout, "kind: Secret") {
+		t.Fatalf("want ConfigMap and Secret on stdout, got %q", stdout)
+	}
+
+	testCases := []struct {
+		name          string
+		args          []string
+		expectedFiles map[string]string
+		expectedError error
+	}{
+		{
+			name: "output file holds the output of stdout",
+			args: []string{"--output", filepath.Join(tmpDir, "output/app.yaml")},
+			expectedFiles: map[string]string{
+				"output/app.yaml": stdout,
+			},
+		},
+		{
+			name: "output directory holds the ConfigMap and the Secret",
+			args: []string{"--output-dir", filepath.Join(tmpDir, "output-dir")},
+			expectedFiles: map[string]string{
+				"output-dir/configmap.yaml": stdout[:strings.Index(stdout, "---\napiVersion: v1\ndata:\n  secret")],
+				"output-dir/secret.yaml":    stdout[strings.Index(stdout, "---\napiVersion: v1\ndata:\n  secret"):],
+			},
+		},
+		{
+			name: "output directory holds the raw documents",
+			args: []string{"--output-dir", filepath.Join(tmpDir, "output-raw"), "--raw"},
+			expectedFiles: map[string]string{
+				"output-raw/configmap-values.yaml": "---\n" + fs.ExpectedConfigmap + "\n",
+				"output-raw/secret-values.yaml":    "---\n" + fs.ExpectedSecret + "\n",
+			},
+		},
+		{
+			name:          "output file and directory are mutually exclusive",
+			args:          []string{"--output", filepath.Join(tmpDir, "excluded.yaml"), "--output-dir", filepath.Join(tmpDir, "excluded")},
+			expectedError: &InvalidFlagError{},
+		},
+		{
+			name:          "output file cannot be used in watch mode",
+			args:          []string{"--output", filepath.Join(tmpDir, "watched.yaml"), "--watch"},
+			expectedError: &InvalidFlagError{},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d: %s", i, tc.name), func(t *testing.T) {
+			out, err := runRender(append(args, tc.args...)...)
+
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Fatalf("want %T, got: %v", tc.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			if out != "" {
+				t.Fatalf("want no output on stdout, got %q", out)
+			}
+
+			for name, expected := range tc.expectedFiles {
+				path := filepath.Join(tmpDir, name)
+
+				content, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("want nil, got error: %s", err)
+				}
+
+				if string(content) != expected {
+					t.Fatalf("want %s to hold %q, got %q", name, expected, content)
+				}
+
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("want nil, got error: %s", err)
+				}
+
+				if info.Mode().Perm() != 0o600 {
+					t.Fatalf("want %s to have mode %o, got %o", name, 0o600, info.Mode().Perm())
+				}
+			}
+		})
+	}
+}
+
+// runRender runs the render command with the arguments and returns what it
+// printed to stdout.
+func runRender(args ...string) (string, error) {
+	var stdout bytes.Buffer
+
+	cmd, err := New(Config{
+		Logger: logr.Discard(),
+		Stderr: io.Discard,
+		Stdout: &stdout,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err = cmd.Execute()
+
+	return stdout.String(), err
+}
+
+func TestRunner_sourceSchema(t *testing.T) {
+	latestDir := filepath.Join(t.TempDir(), "latest")
+
+	testCases := []struct {
+		name           string
+		schema         string
+		expectedSchema string
+	}{
+		{
+			name:           "relative schema is read from the artifact",
+			schema:         "schema.yaml",
+			expectedSchema: filepath.Join(latestDir, "schema.yaml"),
+		},
+		{
+			name:           "relative schema in a directory of the artifact",
+			schema:         "./schemas/../schemas/app.yaml",
+			expectedSchema: filepath.Join(latestDir, "schemas/app.yaml"),
+		},
+		{
+			name:           "absolute schema is read from the local filesystem",
+			schema:         "/local/schema.yaml",
+			expectedSchema: "/local/schema.yaml",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d: %s", i, tc.name), func(t *testing.T) {
+			r := &runner{
+				flag: &flag{Schema: tc.schema, FromGitRepository: "flux-giantswarm/giantswarm-config"},
+			}
+
+			schema := r.sourceSchema(latestDir)
+			if schema != tc.expectedSchema {
+				t.Fatalf("want schema %q, got %q", tc.expectedSchema, schema)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/giantswarm/konfigure/v2/pkg/diff"
+	"github.com/giantswarm/konfigure/v2/pkg/filesystem"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+)
+
+// watchDebounce is how long the watch mode waits for more changes before
+// rendering again, so saving many files at once renders only once.
+const watchDebounce = 200 * time.Millisecond
+
+// rendered holds the data of a render in watch mode.
+type rendered struct {
+	configMapData string
+	secretData    string
+}
+
+// watch renders the configuration again whenever it changes, until
+// interrupted. The artifact of a Flux source is polled every --watch-interval,
+// --dir and the schema are watched for changes of the files used by the
+// previous render otherwise. Failed renders are logged and the watch goes on.
+func (r *runner) watch(ctx context.Context, dynamicService *service.DynamicService) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if sourceKind, source := r.flag.source(); source != "" {
+		return r.pollSource(ctx, dynamicService, sourceKind, source)
+	}
+
+	return r.watchDir(ctx, dynamicService)
+}
+
+// pollSource updates the artifact of the Flux source every --watch-interval
+// and renders it whenever a new revision arrives.
+func (r *runner) pollSource(ctx context.Context, dynamicService *service.DynamicService, sourceKind, source string) error {
+	fluxUpdater, err := r.newFluxUpdater(sourceKind, source)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(r.flag.WatchInterval)
+	defer ticker.Stop()
+
+	var (
+		previous *rendered
+		revision string
+	)
+
+	for {
+		r.logger.Info("Fetching source artifact...", "kind", sourceKind, "source", source, "cacheDir", fluxUpdater.CacheDir)
+
+		err = fluxUpdater.UpdateConfig()
+		if err != nil {
+			r.logger.Error(err, "Failed to fetch source artifact, retrying", "interval", r.flag.WatchInterval)
+		} else {
+			latest, err := fluxUpdater.LatestRevision()
+			if err != nil {
+				return err
+			}
+
+			if previous == nil || latest != revision {
+				revision = latest
+
+				current, err := r.renderWatched(previous, func() (string, string, error) {
+					return dynamicService.RenderRaw(fluxUpdater.LatestDir(), r.sourceSchema(fluxUpdater.LatestDir()), r.flag.Variables)
+				})
+				if err != nil {
+					return err
+				}
+
+				previous = current
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchDir renders --dir whenever the schema, a schema it extends, or a file
+// the previous render read, looked up or listed the directory of, changes.
+func (r *runner) watchDir(ctx context.Context, dynamicService *service.DynamicService) error {
+	dir, err := filepath.Abs(r.flag.Dir)
+	if err != nil {
+		return err
+	}
+
+	schema, err := filepath.Abs(r.flag.Schema)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close() // nolint:errcheck
+
+	// Editors often replace files instead of writing them, so the directory
+	// of the schema is watched instead of the file.
+	err = watcher.Add(filepath.Dir(schema))
+	if err != nil {
+		return err
+	}
+
+	err = addWatchedDirs(watcher, dir, func(string) {})
+	if err != nil {
+		return err
+	}
+
+	var (
+		trackingFS  *filesystem.TrackingFS
+		schemaFiles map[string]struct{}
+		previous    *rendered
+		changed     bool
+	)
+
+	// watchSchemas watches the schema files the schema extends, which may be
+	// anywhere, e.g. in a directory of shared base schemas.
+	watchSchemas := func() {
+		// Errors loading the schema are reported by the render.
+		paths, _ := renderer.SchemaFiles(schema)
+
+		schemaFiles = make(map[string]struct{}, len(paths))
+		for _, path := range paths {
+			path, err := filepath.Abs(path)
+			if err != nil {
+				continue
+			}
+
+			schemaFiles[path] = struct{}{}
+
+			// Extended schemas may be created later, their directory too.
+			err = watcher.Add(filepath.Dir(path))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				r.logger.Error(err, "Failed to watch directory", "dir", filepath.Dir(path))
+			}
+		}
+	}
+
+	// watchTrackedDirs watches the directories outside of --dir the render
+	// read files from, e.g. the directory `../shared` of a layer.
+	watchTrackedDirs := func() {
+		for _, name := range trackingFS.Dirs() {
+			trackedDir := filepath.Join(dir, filepath.FromSlash(name))
+			if isWithin(dir, trackedDir) {
+				continue
+			}
+
+			err := watcher.Add(trackedDir)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				r.logger.Error(err, "Failed to watch directory", "dir", trackedDir)
+			}
+		}
+	}
+
+	render := func() error {
+		// Like a single render, layers and includes may read files outside of
+		// --dir through `..`.
+		trackingFS = filesystem.NewTrackingFS(renderer.LegacyDirFS(dir))
+
+		current, err := r.renderWatched(previous, func() (string, string, error) {
+			return dynamicService.RenderRawFiles(trackingFS, schema, r.flag.Variables)
+		})
+		if err != nil {
+			return err
+		}
+
+		if current != nil {
+			previous = current
+		}
+
+		watchSchemas()
+		watchTrackedDirs()
+
+		return nil
+	}
+
+	// used returns whether the file is the schema, a schema it extends, or used
+	// by the previous render.
+	used := func(path string) bool {
+		if _, found := schemaFiles[filepath.Clean(path)]; found {
+			return true
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false
+		}
+
+		return trackingFS.Uses(filepath.ToSlash(relativePath))
+	}
+
+	err = render()
+	if err != nil {
+		return err
+	}
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if used(event.Name) {
+				changed = true
+			}
+
+			// New directories are watched as well, files created in them
+			// before they were watched are checked right away.
+			if event.Has(fsnotify.Create) {
+				info, err := os.Stat(event.Name)
+				if err == nil && info.IsDir() && isWithin(dir, event.Name) {
+					err = addWatchedDirs(watcher, event.Name, func(path string) {
+						if used(path) {
+							changed = true
+						}
+					})
+					if err != nil {
+						r.logger.Error(err, "Failed to watch directory", "dir", event.Name)
+					}
+				}
+			}
+
+			if changed {
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			r.logger.Error(err, "Failed to watch files")
+		case <-debounce.C:
+			if !changed {
+				continue
+			}
+
+			changed = false
+
+			err = render()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// renderWatched renders and prints the result, or the changes since the
+// previous result with --watch-diff. It returns nil if the render failed, only
+// failures to print are returned as errors.
+func (r *runner) renderWatched(previous *rendered, render func() (string, string, error)) (*rendered, error) {
+	configMapData, secretData, err := render()
+	if err != nil {
+		r.logger.Error(err, "Failed to render, waiting for changes")
+		return nil, nil
+	}
+
+	current := &rendered{configMapData: configMapData, secretData: secretData}
+
+	if r.flag.WatchDiff && previous != nil {
+		err = r.printChanges(*previous, *current)
+	} else {
+		err = r.printRendered(*current)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// printRendered prints the rendered data like a single render does.
+func (r *runner) printRendered(current rendered) error {
+	if r.flag.Raw {
+		return r.writeDocuments(rawDocuments(current.configMapData, current.secretData))
+	}
+
+	configMap := renderer.WrapIntoConfigMap(current.configMapData, r.flag.Name, r.flag.Namespace, nil, nil, r.flag.ConfigMapDataKey)
+	secret := renderer.WrapIntoSecret(current.secretData, r.flag.Name, r.flag.Namespace, nil, nil, r.flag.SecretDataKey)
+
+	return r.writeDocuments(wrappedDocuments(configMap, secret))
+}
+
+// printChanges prints the changes of the rendered data since the previous
+// render in the format of the diff command.
+func (r *runner) printChanges(previous, current rendered) error {
+	configMapChanges, err := diff.YAML(previous.configMapData, current.configMapData)
+	if err != nil {
+		return err
+	}
+
+	secretChanges, err := diff.YAML(previous.secretData, current.secretData)
+	if err != nil {
+		return err
+	}
+
+	if !r.flag.ShowSecrets {
+		secretChanges = diff.Redact(secretChanges)
+	}
+
+	_, err = fmt.Fprintf(r.stdout, "--- %s\n", time.Now().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	if len(configMapChanges)+len(secretChanges) == 0 {
+		_, err = fmt.Fprintln(r.stdout, "No differences found.")
+		return err
+	}
+
+	sections := []struct {
+		title   string
+		changes []diff.Change
+	}{
+		{title: "ConfigMap", changes: configMapChanges},
+		{title: "Secret", changes: secretChanges},
+	}
+
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+
+		_, err = fmt.Fprintf(r.stdout, "%s:\n", section.title)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range section.changes {
+			_, err = fmt.Fprintf(r.stdout, "  %s\n", change.Format())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addWatchedDirs watches the directory and its subdirectories, except for git
+// metadata, and calls visit with every file in them.
+func addWatchedDirs(watcher *fsnotify.Watcher, root string, visit func(path string)) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			visit(path)
+			return nil
+		}
+
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+func isWithin(dir, path string) bool {
+	relativePath, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(relativePath)
+}
@@ -0,0 +1,210 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+)
+
+const watchTestBaseSchema = `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:
+  - id: base
+    path:
+      directory: base
+      required: true
+    values:
+      configMap:
+        name: %s
+    templates:
+      configMap:
+        name: template.yaml
+        required: true
+`
+
+// syncBuffer is a buffer safe for writing while it is read.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buffer.String()
+}
+
+func TestRunner_watchDirExtendedSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// The base schema is neither in --dir nor next to the schema extending it.
+	files := map[string]string{
+		"base/schema.yaml": fmt.Sprintf(watchTestBaseSchema, "values.yaml"),
+		"schemas/app.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: ../base/schema.yaml
+`,
+		"config/base/values.yaml":   "x: 1\n",
+		"config/base/other.yaml":    "x: 2\n",
+		"config/base/template.yaml": "value: {{ .x }}\n",
+	}
+
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(tmpDir, name), content)
+	}
+
+	stdout := &syncBuffer{}
+
+	r := &runner{
+		flag: &flag{
+			Dir:    filepath.Join(tmpDir, "config"),
+			Schema: filepath.Join(tmpDir, "schemas/app.yaml"),
+			Raw:    true,
+			Watch:  true,
+		},
+		logger: logr.Discard(),
+		stdout: stdout,
+		stderr: &bytes.Buffer{},
+	}
+
+	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+		Log:   logr.Discard(),
+		Cache: renderer.NewCache(),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- r.watchDir(ctx, dynamicService)
+	}()
+
+	waitForOutput(t, stdout, "value: 1")
+
+	writeTestFile(t, filepath.Join(tmpDir, "base/schema.yaml"), fmt.Sprintf(watchTestBaseSchema, "other.yaml"))
+
+	waitForOutput(t, stdout, "value: 2")
+
+	cancel()
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+}
+
+func TestRunner_watchDirOutsideDir(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// The layer reads its files from a directory next to --dir.
+	files := map[string]string{
+		"config/schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:
+  - id: shared
+    path:
+      directory: ../shared
+      required: true
+    values:
+      configMap:
+        name: values.yaml
+    templates:
+      configMap:
+        name: template.yaml
+        required: true
+`,
+		"shared/values.yaml":   "x: 1\n",
+		"shared/template.yaml": "value: {{ .x }}\n",
+	}
+
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(tmpDir, name), content)
+	}
+
+	stdout := &syncBuffer{}
+
+	r := &runner{
+		flag: &flag{
+			Dir:    filepath.Join(tmpDir, "config"),
+			Schema: filepath.Join(tmpDir, "config/schema.yaml"),
+			Raw:    true,
+			Watch:  true,
+		},
+		logger: logr.Discard(),
+		stdout: stdout,
+		stderr: &bytes.Buffer{},
+	}
+
+	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+		Log:   logr.Discard(),
+		Cache: renderer.NewCache(),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- r.watchDir(ctx, dynamicService)
+	}()
+
+	waitForOutput(t, stdout, "value: 1")
+
+	writeTestFile(t, filepath.Join(tmpDir, "shared/values.yaml"), "x: 2\n")
+
+	waitForOutput(t, stdout, "value: 2")
+
+	cancel()
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+}
+
+func waitForOutput(t *testing.T, stdout *syncBuffer, expected string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(stdout.String(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("want output containing %q, got %q", expected, stdout.String())
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	err = os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+}
@@ -0,0 +1,55 @@
+package renderall
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	name        = "render-all"
+	description = "Render a schema for every entry of a matrix file in one process."
+)
+
+type Config struct {
+	Logger logr.Logger
+	Stderr io.Writer
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	f := &flag{}
+
+	r := &runner{
+		flag:   f,
+		logger: config.Logger,
+		stderr: config.Stderr,
+		stdout: config.Stdout,
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long: description + `
+
+Every entry of the matrix sets the variables, name and namespace of the rendered
+ConfigMap and Secret, which are written to the output file of the entry in
+--output-dir. Loaded and decrypted files are reused for all entries.`,
+		Args: cobra.NoArgs,
+		RunE: r.Run,
+	}
+
+	f.Init(c)
+
+	return c, nil
+}
@@ -0,0 +1,17 @@
+package renderall
+
+import (
+	"reflect"
+)
+
+type InvalidFlagError struct {
+	message string
+}
+
+func (e *InvalidFlagError) Error() string {
+	return "InvalidFlagError: " + e.message
+}
+
+func (e *InvalidFlagError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
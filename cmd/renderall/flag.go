@@ -0,0 +1,76 @@
+package renderall
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv/key"
+)
+
+const (
+	flagSchema           = "schema"
+	flagDir              = "dir"
+	flagMatrix           = "matrix"
+	flagOutputDir        = "output-dir"
+	flagSOPSKeysSource   = "sops-keys-source"
+	flagSOPSKeysDir      = "sops-keys-dir"
+	flagConfigMapDataKey = "config-map-data-key"
+	flagSecretDataKey    = "secret-data-key"
+	flagWorkers          = "workers"
+)
+
+type flag struct {
+	Schema           string
+	Dir              string
+	Matrix           string
+	OutputDir        string
+	SOPSKeysDir      string
+	SOPSKeysSource   string
+	ConfigMapDataKey string
+	SecretDataKey    string
+	Workers          int
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().StringVar(&f.Dir, flagDir, ".", `Directory containing configuration source (e.g cloned "giantswarm/config" repo).`)
+	cmd.Flags().StringVar(&f.Matrix, flagMatrix, "", `Path to the matrix file listing the variables, name and namespace of every render.`)
+	cmd.Flags().StringVar(&f.OutputDir, flagOutputDir, ".", `Directory the rendered ConfigMaps and Secrets are written to.`)
+	cmd.Flags().StringVar(&f.SOPSKeysDir, flagSOPSKeysDir, "", `Directory containing SOPS private keys (optional).`)
+	cmd.Flags().StringVar(&f.SOPSKeysSource, flagSOPSKeysSource, "local", `Source of SOPS private keys, supports "local" and "kubernetes", (optional).`)
+	cmd.Flags().StringVar(&f.ConfigMapDataKey, flagConfigMapDataKey, model.DefaultConfigMapDataKey, `The key to store the rendered data in the generated ConfigMaps.`)
+	cmd.Flags().StringVar(&f.SecretDataKey, flagSecretDataKey, model.DefaultSecretDataKey, `The key to store the rendered data in the generated Secrets.`)
+	cmd.Flags().IntVar(&f.Workers, flagWorkers, runtime.NumCPU(), `Number of matrix entries rendered in parallel.`)
+}
+
+func (f *flag) Validate() error {
+	if f.Schema == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSchema)}
+	}
+	if f.Dir == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagDir)}
+	}
+	if f.Matrix == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagMatrix)}
+	}
+	if f.OutputDir == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagOutputDir)}
+	}
+	if f.SOPSKeysSource != key.KeysSourceLocal && f.SOPSKeysSource != key.KeysSourceKubernetes {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagSOPSKeysSource, "local,kubernetes")}
+	}
+	if f.ConfigMapDataKey == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagConfigMapDataKey)}
+	}
+	if f.SecretDataKey == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSecretDataKey)}
+	}
+	if f.Workers < 1 {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be at least 1", flagWorkers)}
+	}
+
+	return nil
+}
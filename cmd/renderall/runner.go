@@ -0,0 +1,109 @@
+package renderall
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv"
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	matrix, err := service.LoadRenderMatrix(r.flag.Matrix)
+	if err != nil {
+		return err
+	}
+
+	// Setup SOPS environment
+	sopsEnv, err := sopsenv.NewSOPSEnv(sopsenv.SOPSEnvConfig{
+		KeysDir:    r.flag.SOPSKeysDir,
+		KeysSource: r.flag.SOPSKeysSource,
+		Logger:     r.logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = sopsEnv.Setup(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer sopsEnv.Cleanup()
+
+	dynamicService := service.NewDynamicService(service.DynamicServiceConfig{
+		Log: r.logger,
+	})
+
+	return dynamicService.RenderAll(service.RenderAllInput{
+		Dir:              r.flag.Dir,
+		Schema:           r.flag.Schema,
+		Matrix:           matrix,
+		ConfigMapDataKey: r.flag.ConfigMapDataKey,
+		SecretDataKey:    r.flag.SecretDataKey,
+		Workers:          r.flag.Workers,
+	}, r.writeResult)
+}
+
+// writeResult writes the ConfigMap and Secret of the entry to its output file,
+// in the same format as the render command prints them.
+func (r *runner) writeResult(result service.RenderAllResult) error {
+	var out bytes.Buffer
+
+	err := utils.PrettyPrint(&out, result.ConfigMap)
+	if err != nil {
+		return err
+	}
+
+	err = utils.PrettyPrint(&out, result.Secret)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.flag.OutputDir, result.Entry.Output)
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755) // nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, out.Bytes(), 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(r.stdout, "%s/%s written to %s\n", result.Entry.Namespace, result.Entry.Name, path)
+	return err
+}
@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/cmd/schema/migrate"
+)
+
+const (
+	name        = "schema"
+	description = "Manage schema files."
+)
+
+type Config struct {
+	Logger logr.Logger
+	Stderr io.Writer
+	Stdout io.Writer
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+
+	c := &cobra.Command{
+		Use:   name,
+		Short: description,
+		Long:  description,
+	}
+
+	// Add sub-commands
+	{
+		cmd, err := migrate.New(migrate.Config{
+			Logger: config.Logger,
+			Stderr: config.Stderr,
+			Stdout: config.Stdout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.AddCommand(cmd)
+	}
+
+	return c, nil
+}
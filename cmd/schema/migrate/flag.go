@@ -0,0 +1,30 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagSchema  = "schema"
+	flagInPlace = "in-place"
+)
+
+type flag struct {
+	Schema  string
+	InPlace bool
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Schema, flagSchema, "", `Path to the schema file.`)
+	cmd.Flags().BoolVar(&f.InPlace, flagInPlace, false, `Overwrite the schema file instead of printing the migrated schema.`)
+}
+
+func (f *flag) Validate() error {
+	if f.Schema == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagSchema)}
+	}
+
+	return nil
+}
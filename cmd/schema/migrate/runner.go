@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+)
+
+type runner struct {
+	flag   *flag
+	logger logr.Logger
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	path := filepath.Clean(r.flag.Schema)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := renderer.MigrateSchema(content)
+	if err != nil {
+		return err
+	}
+
+	if !r.flag.InPlace {
+		_, err = r.stdout.Write(migrated)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, migrated, info.Mode().Perm())
+}
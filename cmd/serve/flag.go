@@ -0,0 +1,44 @@
+package serve
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv/key"
+)
+
+const (
+	flagListenAddress  = "listen-address"
+	flagDir            = "dir"
+	flagSOPSKeysSource = "sops-keys-source"
+	flagSOPSKeysDir    = "sops-keys-dir"
+)
+
+type flag struct {
+	ListenAddress  string
+	Dir            string
+	SOPSKeysDir    string
+	SOPSKeysSource string
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.ListenAddress, flagListenAddress, ":8080", `Address to serve the HTTP API on.`)
+	cmd.Flags().StringVar(&f.Dir, flagDir, ".", `Directory containing configuration source (e.g cloned "giantswarm/config" repo), schema paths of requests are relative to it.`)
+	cmd.Flags().StringVar(&f.SOPSKeysDir, flagSOPSKeysDir, "", `Directory containing SOPS private keys used by requests not sending their own keys (optional).`)
+	cmd.Flags().StringVar(&f.SOPSKeysSource, flagSOPSKeysSource, "local", `Source of SOPS private keys used by requests not sending their own keys, supports "local" and "kubernetes", (optional).`)
+}
+
+func (f *flag) Validate() error {
+	if f.ListenAddress == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagListenAddress)}
+	}
+	if f.Dir == "" {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must not be empty", flagDir)}
+	}
+	if f.SOPSKeysSource != key.KeysSourceLocal && f.SOPSKeysSource != key.KeysSourceKubernetes {
+		return &InvalidFlagError{message: fmt.Sprintf("--%s must be one of: %s", flagSOPSKeysSource, "local,kubernetes")}
+	}
+
+	return nil
+}
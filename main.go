@@ -10,7 +10,14 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/giantswarm/konfigure/v2/cmd/diff"
+	"github.com/giantswarm/konfigure/v2/cmd/explain"
+	"github.com/giantswarm/konfigure/v2/cmd/fetch"
+	"github.com/giantswarm/konfigure/v2/cmd/lint"
 	"github.com/giantswarm/konfigure/v2/cmd/render"
+	"github.com/giantswarm/konfigure/v2/cmd/renderall"
+	"github.com/giantswarm/konfigure/v2/cmd/schema"
+	"github.com/giantswarm/konfigure/v2/cmd/serve"
 	"github.com/giantswarm/konfigure/v2/pkg/project"
 )
 
@@ -61,6 +68,76 @@ func mainE(ctx context.Context) error {
 		}
 		subcommands = append(subcommands, cmd)
 	}
+	{
+		c := renderall.Config{
+			Logger: logger,
+		}
+		cmd, err := renderall.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := explain.Config{
+			Logger: logger,
+		}
+		cmd, err := explain.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := diff.Config{
+			Logger: logger,
+		}
+		cmd, err := diff.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := fetch.Config{
+			Logger: logger,
+		}
+		cmd, err := fetch.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := lint.Config{
+			Logger: logger,
+		}
+		cmd, err := lint.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := serve.Config{
+			Logger: logger,
+		}
+		cmd, err := serve.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
+	{
+		c := schema.Config{
+			Logger: logger,
+		}
+		cmd, err := schema.New(c)
+		if err != nil {
+			return err
+		}
+		subcommands = append(subcommands, cmd)
+	}
 
 	newCommand.SilenceErrors = true
 	newCommand.SilenceUsage = true
@@ -0,0 +1,178 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeAdded   ChangeType = "Added"
+	ChangeTypeRemoved ChangeType = "Removed"
+	ChangeTypeChanged ChangeType = "Changed"
+)
+
+// Change is a difference at a single path between two YAML documents. Paths
+// are dot separated with list indexes in brackets, e.g. `apps.hosts[0]`, the
+// same format the explain command accepts.
+type Change struct {
+	Path string     `json:"path"`
+	Type ChangeType `json:"type"`
+
+	// From is the value in the first document, unset for added paths.
+	From interface{} `json:"from,omitempty"`
+
+	// To is the value in the second document, unset for removed paths.
+	To interface{} `json:"to,omitempty"`
+}
+
+// YAML compares two YAML documents semantically and returns the changes of
+// every scalar, or empty map or list, sorted by path. Key order, formatting
+// and comments are ignored.
+func YAML(from, to string) ([]Change, error) {
+	fromValues, err := flatten(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toValues, err := flatten(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+
+	for path, fromValue := range fromValues {
+		toValue, found := toValues[path]
+		switch {
+		case !found:
+			changes = append(changes, Change{Path: path, Type: ChangeTypeRemoved, From: fromValue})
+		case !reflect.DeepEqual(fromValue, toValue):
+			changes = append(changes, Change{Path: path, Type: ChangeTypeChanged, From: fromValue, To: toValue})
+		}
+	}
+
+	for path, toValue := range toValues {
+		if _, found := fromValues[path]; !found {
+			changes = append(changes, Change{Path: path, Type: ChangeTypeAdded, To: toValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// Redact replaces the values of the changes with utils.RedactedValue, keeping
+// their paths and types.
+func Redact(changes []Change) []Change {
+	redacted := make([]Change, 0, len(changes))
+
+	for _, change := range changes {
+		if change.Type != ChangeTypeAdded {
+			change.From = utils.RedactedValue
+		}
+		if change.Type != ChangeTypeRemoved {
+			change.To = utils.RedactedValue
+		}
+
+		redacted = append(redacted, change)
+	}
+
+	return redacted
+}
+
+// Format returns the change as a single line, e.g. `~ apps.replicas: 1 -> 3`.
+func (c Change) Format() string {
+	switch c.Type {
+	case ChangeTypeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, formatValue(c.To))
+	case ChangeTypeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, formatValue(c.From))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, formatValue(c.From), formatValue(c.To))
+	}
+}
+
+func formatValue(value interface{}) string {
+	if value == utils.RedactedValue {
+		return utils.RedactedValue
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(encoded)
+}
+
+// flatten returns the leaf values of the YAML document by their path.
+func flatten(document string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	if strings.TrimSpace(document) == "" {
+		return values, nil
+	}
+
+	jsonDocument, err := yaml.YAMLToJSON([]byte(document))
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are kept as is, so `1` and `1.0` are reported as different.
+	decoder := json.NewDecoder(bytes.NewReader(jsonDocument))
+	decoder.UseNumber()
+
+	var value interface{}
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	if value != nil {
+		flattenValue("", value, values)
+	}
+
+	return values, nil
+}
+
+func flattenValue(path string, value interface{}, values map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && path != "" {
+			values[path] = v
+		}
+
+		for key, item := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			flattenValue(childPath, item, values)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			values[path] = v
+		}
+
+		for i, item := range v {
+			flattenValue(path+"["+strconv.Itoa(i)+"]", item, values)
+		}
+	default:
+		values[path] = v
+	}
+}
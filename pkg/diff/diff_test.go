@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	testCases := []struct {
+		name     string
+		from     string
+		to       string
+		expected []string
+	}{
+		{
+			name: "case 0 - no changes when only formatting and key order differ",
+			from: `a: 1
+b:
+  c: [x, y]
+`,
+			to: `# comment
+b:
+  c:
+    - x
+    - y
+a: 1
+`,
+			expected: nil,
+		},
+		{
+			name: "case 1 - added, removed and changed values",
+			from: `app:
+  replicas: 1
+  debug: true
+  hosts:
+    - a.example.com
+`,
+			to: `app:
+  replicas: 3
+  hosts:
+    - a.example.com
+    - b.example.com
+  image: nginx
+`,
+			expected: []string{
+				`- app.debug: true`,
+				`+ app.hosts[1]: "b.example.com"`,
+				`+ app.image: "nginx"`,
+				`~ app.replicas: 1 -> 3`,
+			},
+		},
+		{
+			name: "case 2 - empty documents and empty collections",
+			from: ``,
+			to: `a: {}
+b: []
+`,
+			expected: []string{
+				`+ a: {}`,
+				`+ b: []`,
+			},
+		},
+		{
+			name: "case 3 - map replaced by a scalar",
+			from: `a:
+  b: 1
+`,
+			to: `a: 1
+`,
+			expected: []string{
+				`+ a: 1`,
+				`- a.b: 1`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := YAML(tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var formatted []string
+			for _, change := range changes {
+				formatted = append(formatted, change.Format())
+			}
+
+			if !reflect.DeepEqual(formatted, tc.expected) {
+				t.Fatalf("changes = %q, want %q", formatted, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	changes, err := YAML("password: hunter2\nuser: admin\n", "password: hunter3\ntoken: abc\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var formatted []string
+	for _, change := range Redact(changes) {
+		formatted = append(formatted, change.Format())
+	}
+
+	expected := []string{
+		`~ password: <redacted> -> <redacted>`,
+		`+ token: <redacted>`,
+		`- user: <redacted>`,
+	}
+
+	if !reflect.DeepEqual(formatted, expected) {
+		t.Fatalf("changes = %q, want %q", formatted, expected)
+	}
+
+	if removed := Redact(changes)[2]; removed.From != "<redacted>" || removed.To != nil {
+		t.Fatalf("removed change = %#v, want only the previous value redacted", removed)
+	}
+}
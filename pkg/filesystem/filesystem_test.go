@@ -0,0 +1,193 @@
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStore_RevisionFS(t *testing.T) {
+	repo := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	writeFile := func(path, content string) {
+		t.Helper()
+
+		err := os.MkdirAll(filepath.Dir(filepath.Join(repo, path)), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(filepath.Join(repo, path), []byte(content), 0o644) // nolint:gosec
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "--quiet")
+	writeFile("README.md", "readme")
+	writeFile("configs/values.yaml", "replicas: 1\n")
+	writeFile("configs/default/templates/app.yaml", "name: app\n")
+	err := os.Symlink("default/templates/app.yaml", filepath.Join(repo, "configs", "app.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	git("tag", "v1.0.0")
+
+	writeFile("configs/values.yaml", "replicas: 3\n")
+	writeFile("configs/new.yaml", "new: true\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "scale")
+
+	store := &Store{Dir: filepath.Join(repo, "configs")}
+
+	revisionFS, err := store.RevisionFS("v1.0.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = fstest.TestFS(revisionFS, "values.yaml", "app.yaml", "default/templates/app.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "case 0 - file at the revision",
+			path:     "values.yaml",
+			expected: "replicas: 1\n",
+		},
+		{
+			name:     "case 1 - symbolic link to a file at the revision",
+			path:     "app.yaml",
+			expected: "name: app\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := fs.ReadFile(revisionFS, tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(content) != tc.expected {
+				t.Fatalf("content = %q, want %q", content, tc.expected)
+			}
+		})
+	}
+
+	for _, path := range []string{"new.yaml", "README.md", "../README.md"} {
+		if _, err := fs.Stat(revisionFS, path); err == nil {
+			t.Fatalf("expected %q not to exist at the revision", path)
+		}
+	}
+
+	_, err = store.RevisionFS("does-not-exist")
+	if err == nil {
+		t.Fatalf("expected error for unknown revision")
+	}
+}
+
+func TestTrackingFS_Uses(t *testing.T) {
+	trackingFS := NewTrackingFS(fstest.MapFS{
+		"0-base/values.yaml":        &fstest.MapFile{Data: []byte("a: 1\n")},
+		"0-base/templates/app.yaml": &fstest.MapFile{Data: []byte("name: app\n")},
+		"1-stages/dev/values.yaml":  &fstest.MapFile{Data: []byte("a: 2\n")},
+	})
+
+	_, err := fs.ReadFile(trackingFS, "0-base/values.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = fs.Glob(trackingFS, "0-base/templates/*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = fs.Stat(trackingFS, "1-stages/prod/values.yaml")
+	if err == nil {
+		t.Fatalf("expected error for a missing file")
+	}
+
+	// Names that are not valid io/fs paths are recorded as well, the
+	// filesystem may support them.
+	_, _ = fs.ReadFile(trackingFS, "0-base/../../shared/values.yaml")
+	_, _ = fs.ReadFile(trackingFS, "/rooted/values.yaml")
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "case 0 - read file",
+			path:     "0-base/values.yaml",
+			expected: true,
+		},
+		{
+			name:     "case 1 - new file in a listed directory",
+			path:     "0-base/templates/new.yaml",
+			expected: true,
+		},
+		{
+			name:     "case 2 - missing file that was looked up",
+			path:     "1-stages/prod/values.yaml",
+			expected: true,
+		},
+		{
+			name:     "case 3 - unused file",
+			path:     "1-stages/dev/values.yaml",
+			expected: false,
+		},
+		{
+			name:     "case 4 - file in a subdirectory of a listed directory",
+			path:     "0-base/templates/nested/app.yaml",
+			expected: false,
+		},
+		{
+			name:     "case 5 - file outside of the root",
+			path:     "../shared/values.yaml",
+			expected: true,
+		},
+		{
+			name:     "case 6 - rooted file",
+			path:     "rooted/values.yaml",
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if used := trackingFS.Uses(tc.path); used != tc.expected {
+				t.Fatalf("Uses(%q) = %t, want %t", tc.path, used, tc.expected)
+			}
+		})
+	}
+
+	expectedDirs := []string{"../shared", "0-base", "0-base/templates", "1-stages/prod", "rooted"}
+	if dirs := trackingFS.Dirs(); !reflect.DeepEqual(dirs, expectedDirs) {
+		t.Fatalf("Dirs() = %v, want %v", dirs, expectedDirs)
+	}
+}
@@ -0,0 +1,335 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gitModeDir     = "040000"
+	gitModeSymlink = "120000"
+	gitModeExec    = "100755"
+
+	// maxSymlinkHops limits following symbolic links, like the kernel does.
+	maxSymlinkHops = 40
+)
+
+// GitFS is a read-only filesystem of a directory of a git repository at a
+// revision. The tree is listed when the filesystem is created, file contents
+// are read from git when first needed. It is safe for concurrent use.
+type GitFS struct {
+	dir      string
+	revision string
+	entries  map[string]*gitEntry
+
+	mu       sync.Mutex
+	contents map[string][]byte
+}
+
+type gitEntry struct {
+	name     string
+	mode     string
+	object   string
+	size     int64
+	children []string
+}
+
+// RevisionFS returns the files of Store.Dir at the given git revision, e.g. a
+// branch, tag or commit, without checking it out. Only the files below
+// Store.Dir are included when it is a subdirectory of the git repository.
+func (s *Store) RevisionFS(revision string) (*GitFS, error) {
+	commit, err := s.git("rev-parse", "--verify", "--end-of-options", revision+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %q of %q: %w", revision, s.Dir, err)
+	}
+
+	prefix, err := s.git("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	// Without --full-tree, ls-tree only lists the tree entries matching the
+	// working directory of git.
+	listing, err := s.git("ls-tree", "--full-tree", "-r", "-t", "-l", "-z", strings.TrimSpace(commit)+":"+strings.TrimSpace(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision %q of %q: %w", revision, s.Dir, err)
+	}
+
+	gitFS := &GitFS{
+		dir:      s.Dir,
+		revision: strings.TrimSpace(commit),
+		entries:  map[string]*gitEntry{".": {name: ".", mode: gitModeDir}},
+		contents: make(map[string][]byte),
+	}
+
+	for _, line := range strings.Split(listing, "\x00") {
+		if line == "" {
+			continue
+		}
+
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		info, name, found := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", line)
+		}
+
+		// Submodules are not part of the tree.
+		if fields[1] == "commit" {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+
+		gitFS.entries[name] = &gitEntry{
+			name:   path.Base(name),
+			mode:   fields[0],
+			object: fields[2],
+			size:   size,
+		}
+
+		parent := gitFS.entries[path.Dir(name)]
+		if parent != nil {
+			parent.children = append(parent.children, name)
+		}
+	}
+
+	return gitFS, nil
+}
+
+// Revision returns the commit the filesystem was created from.
+func (f *GitFS) Revision() string {
+	return f.revision
+}
+
+func (f *GitFS) Open(name string) (fs.File, error) {
+	entry, resolved, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.mode == gitModeDir {
+		entries, err := f.readDir(resolved, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		return &gitDir{info: gitFileInfo{entry: entry, name: path.Base(name)}, entries: entries}, nil
+	}
+
+	content, err := f.readBlob(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &gitFile{info: gitFileInfo{entry: entry, name: path.Base(name)}, reader: bytes.NewReader(content)}, nil
+}
+
+func (f *GitFS) ReadFile(name string) ([]byte, error) {
+	entry, _, err := f.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.mode == gitModeDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+
+	content, err := f.readBlob(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return append([]byte(nil), content...), nil
+}
+
+func (f *GitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, resolved, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.mode != gitModeDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+
+	return f.readDir(resolved, entry)
+}
+
+func (f *GitFS) Stat(name string) (fs.FileInfo, error) {
+	entry, _, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return gitFileInfo{entry: entry, name: path.Base(name)}, nil
+}
+
+// lookup returns the entry at name and its path, following symbolic links.
+func (f *GitFS) lookup(op, name string) (*gitEntry, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	resolved := name
+
+	for hops := 0; ; hops++ {
+		entry, found := f.entries[resolved]
+		if !found {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		if entry.mode != gitModeSymlink {
+			return entry, resolved, nil
+		}
+
+		if hops == maxSymlinkHops {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+		}
+
+		target, err := f.readBlob(entry)
+		if err != nil {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: err}
+		}
+
+		// Links pointing outside of the filesystem do not exist in it.
+		resolved = path.Join(path.Dir(resolved), string(target))
+		if !fs.ValidPath(resolved) {
+			return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+}
+
+func (f *GitFS) readDir(name string, entry *gitEntry) ([]fs.DirEntry, error) {
+	entries := make([]fs.DirEntry, 0, len(entry.children))
+
+	for _, child := range entry.children {
+		info, err := f.Stat(child)
+		if err != nil {
+			// Dangling symbolic links are listed as they are.
+			info = gitFileInfo{entry: f.entries[child], name: path.Base(child)}
+		}
+
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (f *GitFS) readBlob(entry *gitEntry) ([]byte, error) {
+	f.mu.Lock()
+	content, found := f.contents[entry.object]
+	f.mu.Unlock()
+
+	if found {
+		return content, nil
+	}
+
+	out, err := (&Store{Dir: f.dir}).git("cat-file", "blob", entry.object)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.contents[entry.object] = []byte(out)
+	f.mu.Unlock()
+
+	return []byte(out), nil
+}
+
+// git runs the git command in Store.Dir and returns its output.
+func (s *Store) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.Dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+type gitFileInfo struct {
+	entry *gitEntry
+	name  string
+}
+
+func (i gitFileInfo) Name() string       { return i.name }
+func (i gitFileInfo) Size() int64        { return i.entry.size }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return i.entry.mode == gitModeDir }
+func (i gitFileInfo) Sys() interface{}   { return nil }
+
+func (i gitFileInfo) Mode() fs.FileMode {
+	switch i.entry.mode {
+	case gitModeDir:
+		return fs.ModeDir | 0o555
+	case gitModeSymlink:
+		return fs.ModeSymlink | 0o777
+	case gitModeExec:
+		return 0o555
+	default:
+		return 0o444
+	}
+}
+
+type gitFile struct {
+	info   gitFileInfo
+	reader *bytes.Reader
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Read(b []byte) (int, error) { return f.reader.Read(b) }
+func (f *gitFile) Close() error               { return nil }
+
+type gitDir struct {
+	info    gitFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *gitDir) Close() error               { return nil }
+
+func (d *gitDir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *gitDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+
+	d.offset += count
+
+	return remaining[:count], nil
+}
@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// TrackingFS records the files and directories read through it, e.g. to tell
+// which files a render depends on. Paths of missing files are recorded as
+// well, so creating an optional file counts as a change of a used file. It is
+// safe for concurrent use.
+type TrackingFS struct {
+	fsys fs.FS
+
+	mu    sync.Mutex
+	files map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+func NewTrackingFS(fsys fs.FS) *TrackingFS {
+	return &TrackingFS{
+		fsys:  fsys,
+		files: make(map[string]struct{}),
+		dirs:  make(map[string]struct{}),
+	}
+}
+
+func (t *TrackingFS) Open(name string) (fs.File, error) {
+	t.recordFile(name)
+	return t.fsys.Open(name)
+}
+
+func (t *TrackingFS) ReadFile(name string) ([]byte, error) {
+	t.recordFile(name)
+	return fs.ReadFile(t.fsys, name)
+}
+
+// ReadDir records the directory, so files created in it count as changes,
+// e.g. new files matching a glob pattern.
+func (t *TrackingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	t.mu.Lock()
+	t.dirs[clean(name)] = struct{}{}
+	t.mu.Unlock()
+
+	return fs.ReadDir(t.fsys, name)
+}
+
+func (t *TrackingFS) Stat(name string) (fs.FileInfo, error) {
+	t.recordFile(name)
+	return fs.Stat(t.fsys, name)
+}
+
+// Uses returns whether the file at the slash separated path was read through
+// the filesystem, or a directory containing it was listed.
+func (t *TrackingFS) Uses(name string) bool {
+	name = clean(name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, found := t.files[name]; found {
+		return true
+	}
+
+	_, found := t.dirs[path.Dir(name)]
+
+	return found
+}
+
+// Dirs returns the directories of the files read and the directories listed
+// through the filesystem in lexical order, e.g. to watch them for changes.
+func (t *TrackingFS) Dirs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dirs := make(map[string]struct{}, len(t.dirs))
+	for dir := range t.dirs {
+		dirs[dir] = struct{}{}
+	}
+	for file := range t.files {
+		dirs[path.Dir(file)] = struct{}{}
+	}
+
+	result := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		result = append(result, dir)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (t *TrackingFS) recordFile(name string) {
+	t.mu.Lock()
+	t.files[clean(name)] = struct{}{}
+	t.mu.Unlock()
+}
+
+// clean returns the name the way the files are recorded. Filesystems accepting
+// names that are not valid io/fs paths, like renderer.LegacyDirFS, resolve
+// rooted names relative to their root, so these are recorded relative to it as
+// well. Names leaving the root keep their leading `..` segments.
+func clean(name string) string {
+	name = path.Clean(name)
+	if path.IsAbs(name) {
+		return path.Clean("." + name)
+	}
+
+	return name
+}
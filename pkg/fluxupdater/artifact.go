@@ -0,0 +1,292 @@
+package fluxupdater
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/tar"
+)
+
+const (
+	cacheRevisions = "revisions"
+
+	// tmpPrefix marks files and directories of updates in progress. They are
+	// left behind by interrupted updates only and removed by the next one.
+	tmpPrefix = ".tmp-"
+
+	digestAlgorithmSHA256 = "sha256"
+	digestAlgorithmSHA384 = "sha384"
+	digestAlgorithmSHA512 = "sha512"
+)
+
+// Revisions returns the directories of the cached revisions of the artifact,
+// the latest first.
+func (u *FluxUpdater) Revisions() ([]string, error) {
+	revisions, err := u.listRevisions()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(revisions))
+	for _, revision := range revisions {
+		dirs = append(dirs, filepath.Join(u.CacheDir, cacheRevisions, revision.Name()))
+	}
+
+	return dirs, nil
+}
+
+// LatestRevision returns the name of the latest revision, which changes
+// whenever a new artifact is unpacked, or an empty string if there is none.
+func (u *FluxUpdater) LatestRevision() (string, error) {
+	// Cache directories of earlier versions have the latest revision unpacked
+	// in place, without a name.
+	info, err := os.Lstat(u.LatestDir())
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	} else if info.Mode()&os.ModeSymlink == 0 {
+		return "", nil
+	}
+
+	target, err := os.Readlink(u.LatestDir())
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Base(target), nil
+}
+
+// verifyDigest checks the content against the digest in the format of
+// <algorithm>:<hex> the source-controller advertises.
+func verifyDigest(content []byte, digest string) error {
+	algorithm, encoded, found := strings.Cut(digest, ":")
+	if !found {
+		return &ExecutionFailedError{message: fmt.Sprintf("invalid artifact digest %q, expected <algorithm>:<hex>", digest)}
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case digestAlgorithmSHA256:
+		h = sha256.New()
+	case digestAlgorithmSHA384:
+		h = sha512.New384()
+	case digestAlgorithmSHA512:
+		h = sha512.New()
+	default:
+		return &ExecutionFailedError{message: fmt.Sprintf("unsupported artifact digest algorithm %q", algorithm)}
+	}
+
+	_, _ = h.Write(content)
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, encoded) {
+		return &ExecutionFailedError{message: fmt.Sprintf("artifact digest mismatch: expected %s, got %s:%s", digest, algorithm, actual)}
+	}
+
+	return nil
+}
+
+// install unpacks the archive into a temporary sibling of its revision
+// directory, renames it to the revision directory and then points the latest
+// directory to it, so the latest directory always holds a complete revision.
+// Revisions beyond KeepRevisions are removed afterwards.
+func (u *FluxUpdater) install(archive io.Reader, digest string) error {
+	revisionsDir := filepath.Join(u.CacheDir, cacheRevisions)
+
+	err := os.MkdirAll(revisionsDir, 0750)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{u.CacheDir, revisionsDir} {
+		err = removeTemporary(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	revision := strings.ReplaceAll(digest, ":", "-")
+	revisionDir := filepath.Join(revisionsDir, revision)
+
+	_, err = os.Stat(revisionDir)
+	if os.IsNotExist(err) {
+		tmpDir, err := os.MkdirTemp(revisionsDir, tmpPrefix)
+		if err != nil {
+			return err
+		}
+
+		err = tar.Untar(archive, tmpDir)
+		if err == nil {
+			err = os.Chmod(tmpDir, 0750) // nolint:gosec
+		}
+		if err == nil {
+			err = os.Rename(tmpDir, revisionDir)
+		}
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else {
+		// The revision is cached already, e.g. when the source rolled back,
+		// it becomes the most recent one.
+		now := time.Now()
+
+		err = os.Chtimes(revisionDir, now, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = u.swapLatest(filepath.Join(cacheRevisions, revision))
+	if err != nil {
+		return err
+	}
+
+	return u.prune(revision)
+}
+
+// swapLatest atomically replaces the latest directory with a symbolic link to
+// the target relative to the cache directory.
+func (u *FluxUpdater) swapLatest(target string) error {
+	latest := u.LatestDir()
+
+	// Cache directories of earlier versions have the latest revision unpacked
+	// in place, it cannot be replaced by a rename.
+	info, err := os.Lstat(latest)
+	if err == nil && info.IsDir() {
+		err = os.RemoveAll(latest)
+		if err != nil {
+			return err
+		}
+	}
+
+	link := filepath.Join(u.CacheDir, tmpPrefix+cacheLatest)
+
+	err = os.Remove(link)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Symlink(target, link)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(link, latest)
+}
+
+// prune removes the revisions beyond KeepRevisions besides the latest one.
+func (u *FluxUpdater) prune(latest string) error {
+	revisions, err := u.listRevisions()
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, revision := range revisions {
+		if revision.Name() == latest {
+			continue
+		}
+
+		if kept < u.KeepRevisions {
+			kept++
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(u.CacheDir, cacheRevisions, revision.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listRevisions returns the revision directories, the most recent first.
+func (u *FluxUpdater) listRevisions() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(u.CacheDir, cacheRevisions))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var revisions []os.FileInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), tmpPrefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, info)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].ModTime().After(revisions[j].ModTime())
+	})
+
+	return revisions, nil
+}
+
+// removeTemporary removes the leftovers of interrupted updates in dir.
+func removeTemporary(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), tmpPrefix) {
+			continue
+		}
+
+		err = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes the file through a temporary file renamed to it, so
+// it is never left half-written.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), tmpPrefix+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0640) // nolint:gosec
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
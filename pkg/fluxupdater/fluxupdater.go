@@ -2,7 +2,7 @@ package fluxupdater
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,44 +13,80 @@ import (
 	"strings"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
-	"github.com/fluxcd/pkg/tar"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	// GS stuff uses `kgs`-generated kubeconfigs that use `oidc` auth
+	// provider, it must be registered to fetch artifacts locally.
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
 
 const (
 	cacheLastArchive          = "lastarchive"
 	cacheLastArchiveTimestamp = "lastarchivetimestamp"
 	cacheLastArtifactUrl      = "lastartifacturl"
+	cacheLatest               = "latest"
+)
 
-	// v1SourceAPIGroup holds Flux Source group and v1 version
-	v1SourceAPIGroup = "source.toolkit.fluxcd.io/v1"
-	// v1beta2SourceAPIGroup holds Flux Source group and v1beta2 version
-	v1beta2SourceAPIGroup = "source.toolkit.fluxcd.io/v1beta2"
+// DefaultKeepRevisions is the number of previous revisions commands keep in
+// the cache directory for rollback unless told otherwise.
+const DefaultKeepRevisions = 2
 
-	// defaultKubernetesTokenFile holds the location of the Kubernetes Service Account
-	// token mount within a Pod.
-	defaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" // #nosec G101
+const (
+	SourceKindGitRepository = "GitRepository"
+	SourceKindOCIRepository = "OCIRepository"
+	SourceKindBucket        = "Bucket"
 )
 
+// sourceResources holds the Flux Source versions the sources of every kind are
+// looked up in, in order of preference.
+var sourceResources = map[string][]schema.GroupVersionResource{
+	SourceKindGitRepository: {
+		{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "gitrepositories"},
+		{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "gitrepositories"},
+	},
+	SourceKindOCIRepository: {
+		{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "ocirepositories"},
+		{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "ocirepositories"},
+	},
+	SourceKindBucket: {
+		{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "buckets"},
+		{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Resource: "buckets"},
+	},
+}
+
 type Config struct {
 	CacheDir string
 
-	ApiServerHost       string
-	ApiServerPort       string
-	KubernetesTokenFile string
+	// RestConfig is used to get the source from the Kubernetes API
+	// server. It defaults to the config controller-runtime resolves from
+	// $KUBECONFIG, the in-cluster config or ~/.kube/config.
+	RestConfig *rest.Config
+
+	// KeepRevisions is the number of previous revisions of the artifact kept
+	// in the cache directory besides the latest one.
+	KeepRevisions int
 
-	GitRepository string
+	// SourceKind is the kind of the Flux source, one of GitRepository,
+	// OCIRepository and Bucket. It defaults to GitRepository.
+	SourceKind string
+	// Source is the Flux source in the format of <namespace>/<name>.
+	Source string
 }
 
 type FluxUpdater struct {
-	CacheDir string
+	CacheDir      string
+	KeepRevisions int
 
-	ApiServerHost       string
-	ApiServerPort       string
-	KubernetesTokenFile string
+	SourceKind string
+	Source     string
 
-	GitRepository string
+	client dynamic.Interface
 }
 
 func New(config Config) (*FluxUpdater, error) {
@@ -58,45 +94,86 @@ func New(config Config) (*FluxUpdater, error) {
 		return nil, &InvalidConfigError{message: "cacheDir must not be empty"}
 	}
 
-	if config.ApiServerHost == "" {
-		return nil, &InvalidConfigError{message: "apiServerHost must not be empty"}
+	if config.KeepRevisions < 0 {
+		return nil, &InvalidConfigError{message: "keepRevisions must not be negative"}
 	}
 
-	if config.ApiServerPort == "" {
-		return nil, &InvalidConfigError{message: "apiServerPort must not be empty"}
+	sourceKind := config.SourceKind
+	if sourceKind == "" {
+		sourceKind = SourceKindGitRepository
+	}
+	if _, found := sourceResources[sourceKind]; !found {
+		return nil, &InvalidConfigError{message: fmt.Sprintf("sourceKind must be one of %s, %s, %s, got %q", SourceKindGitRepository, SourceKindOCIRepository, SourceKindBucket, config.SourceKind)}
 	}
 
-	var kubernetesTokenFile string
-	if config.KubernetesTokenFile == "" {
-		kubernetesTokenFile = defaultKubernetesTokenFile
-	} else {
-		kubernetesTokenFile = config.KubernetesTokenFile
+	if config.Source == "" {
+		return nil, &InvalidConfigError{message: "source must not be empty"}
 	}
+	if _, _, err := splitSource(config.Source); err != nil {
+		return nil, err
+	}
+
+	restConfig := config.RestConfig
+	if restConfig == nil {
+		var err error
 
-	if config.GitRepository == "" {
-		return nil, &InvalidConfigError{message: "gitRepository must not be empty"}
+		restConfig, err = ctrl.GetConfig()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if kubernetesTokenFile == "" {
-		kubernetesTokenFile = defaultKubernetesTokenFile
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FluxUpdater{
-		CacheDir:            config.CacheDir,
-		ApiServerHost:       config.ApiServerHost,
-		ApiServerPort:       config.ApiServerPort,
-		KubernetesTokenFile: kubernetesTokenFile,
-		GitRepository:       config.GitRepository,
+		CacheDir:      config.CacheDir,
+		KeepRevisions: config.KeepRevisions,
+		SourceKind:    sourceKind,
+		Source:        config.Source,
+		client:        client,
 	}, nil
 }
 
+// DefaultCacheDir returns the cache directory of the source of the given kind
+// in the format of <namespace>/<name> below the user cache directory, e.g.
+// ~/.cache/konfigure/gitrepositories/<namespace>/<name> on Linux.
+func DefaultCacheDir(sourceKind, source string) (string, error) {
+	resources, found := sourceResources[sourceKind]
+	if !found {
+		return "", &InvalidConfigError{message: fmt.Sprintf("unknown source kind %q", sourceKind)}
+	}
+
+	namespace, name, err := splitSource(source)
+	if err != nil {
+		return "", err
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(userCacheDir, "konfigure", resources[0].Resource, namespace, name), nil
+}
+
+// LatestDir returns the directory the latest artifact is unpacked to. It is a
+// symbolic link to the latest revision.
+func (u *FluxUpdater) LatestDir() string {
+	return path.Join(u.CacheDir, cacheLatest)
+}
+
 // UpdateConfig makes sure that the assembled CCR version we keep stashed
 // in <cacheDir>/latest is still *the* latest version out there. In order to do that,
 // it sends a HEAD request for the last known artifact to the Source Controller,
 // in order to check it is still available. If so, it then skips further processing.
-// Otherwise, it contacts the GitRepository resource for the new artifact's URL.
-// The URL is then used to download a new version of the archive and untar it.
-// The archive name is being saved for later comparison.
+// Otherwise, it contacts the source resource for the new artifact's URL and digest.
+// The URL is then used to download a new version of the archive, which is only
+// unpacked when it matches the digest. The unpacked revision replaces
+// <cacheDir>/latest atomically, the previous KeepRevisions revisions are kept
+// for rollback.
 func (u *FluxUpdater) UpdateConfig() error {
 	// We first get the 'lastarchivetimestamp' and 'lastartifacturl' files, because it contains the URL
 	//of the artifact we have been using up until now. If the file is gone, it means we haven't populated the cache yet,
@@ -124,6 +201,12 @@ func (u *FluxUpdater) UpdateConfig() error {
 
 	url := string(cacheLastArtifactUrlByte)
 
+	// A cache without the latest revision, e.g. after a crash, is populated
+	// again.
+	if _, err := os.Stat(u.LatestDir()); err != nil {
+		url = ""
+	}
+
 	client := &http.Client{Timeout: 60 * time.Second}
 
 	if url != "" {
@@ -153,128 +236,18 @@ func (u *FluxUpdater) UpdateConfig() error {
 			if cachedArtifactTimestamp.After(artifactTimestamp) || cachedArtifactTimestamp.Equal(artifactTimestamp) {
 				return nil
 			}
-		} else {
-			if response.StatusCode != http.StatusNotFound {
-				return &ExecutionFailedError{
-					message: fmt.Sprintf("error calling %q: expected %d, got %d", request.URL, http.StatusNotFound, response.StatusCode),
-				}
-			} else {
-				url = ""
-			}
-		}
-	}
-
-	// When latest known revision is still available, there is no need to query the API Server
-	// for the GitRepository, it saves us one call.
-	if url == "" {
-		repoCoordinates := strings.Split(u.GitRepository, "/")
-
-		k8sApiPath := []string{
-			fmt.Sprintf(
-				"https://%s:%s/apis/%s/namespaces/%s/gitrepositories/%s",
-				u.ApiServerHost,
-				u.ApiServerPort,
-				v1SourceAPIGroup,
-				repoCoordinates[0],
-				repoCoordinates[1],
-			),
-			fmt.Sprintf(
-				"https://%s:%s/apis/%s/namespaces/%s/gitrepositories/%s",
-				u.ApiServerHost,
-				u.ApiServerPort,
-				v1beta2SourceAPIGroup,
-				repoCoordinates[0],
-				repoCoordinates[1],
-			),
-		}
-
-		k8sToken, err := os.ReadFile(u.KubernetesTokenFile)
-		if err != nil {
-			return err
-		}
-
-		bearer := fmt.Sprintf("Bearer %s", strings.TrimSpace(string(k8sToken)))
-
-		// Make a GET request to the Kubernetes API server to get the GitRepository
-		// in a JSON format.
-		var response *http.Response
-		for _, p := range k8sApiPath {
-			request, err := http.NewRequest(http.MethodGet, p, nil)
-			if err != nil {
-				return err
-			}
-
-			request.Header.Set("Authorization", bearer)
-			request.Header.Add("Accept", "application/json")
-
-			tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} // nolint:gosec
-			client.Transport = tr
-
-			response, err = client.Do(request)
-			if err != nil {
-				return err
-			}
-			defer func() { _ = response.Body.Close() }()
-
-			if response.StatusCode == http.StatusOK {
-				break
-			}
-
-			if response.StatusCode == http.StatusNotFound {
-				continue
-			}
-
-			return &ExecutionFailedError{
-				message: fmt.Sprintf("error calling %q: expected %d, got %d", request.URL, http.StatusOK, response.StatusCode),
-			}
-		}
-
-		if response.StatusCode != http.StatusOK {
-			return &ExecutionFailedError{
-				message: fmt.Sprintf("error getting '%s' GitRepository CR", u.GitRepository),
-			}
-		}
-
-		responseBytes, err := io.ReadAll(response.Body)
-		if err != nil {
-			return err
-		}
-
-		// We are not interested in an entire object, we are only interested in getting
-		// some of the status fields that advertise the new archive.
-		type gitRepository struct {
-			Status struct {
-				Conditions []metav1.Condition `json:"conditions,omitempty"`
-				Artifact   struct {
-					Url string
-				}
-			}
-		}
-
-		var gr gitRepository
-		err = json.Unmarshal(responseBytes, &gr)
-		if err != nil {
-			return err
-		}
-
-		// Note: technically this does not mean an error. An empty field could be a symptom
-		// of the CR still being reconciled, or not being picked up by the Source Controller
-		// at all, in which case, we could simply skip quietly.
-		if gr.Status.Artifact.Url == "" {
-			for _, condition := range gr.Status.Conditions {
-				if condition.Type == "Ready" && condition.Status != metav1.ConditionTrue {
-					return &ExecutionFailedError{
-						message: condition.Message,
-					}
-				}
-			}
-
+		} else if response.StatusCode != http.StatusNotFound {
 			return &ExecutionFailedError{
-				message: "error downloading artifact: got empty URL from GitRepository status",
+				message: fmt.Sprintf("error calling %q: expected %d, got %d", request.URL, http.StatusNotFound, response.StatusCode),
 			}
 		}
+	}
 
-		url = gr.Status.Artifact.Url
+	// The artifact has changed or is not cached yet, the source advertises the
+	// URL and the digest of the new one.
+	url, digest, err := u.getArtifact()
+	if err != nil {
+		return err
 	}
 
 	request, err := http.NewRequest(http.MethodGet, url, nil)
@@ -286,12 +259,13 @@ func (u *FluxUpdater) UpdateConfig() error {
 	if err != nil {
 		return err
 	}
+	defer func() { _ = response.Body.Close() }()
+
 	if response.StatusCode != http.StatusOK {
 		return &ExecutionFailedError{
 			message: fmt.Sprintf("error calling %q: expected %d, got %d", request.URL, http.StatusOK, response.StatusCode),
 		}
 	}
-	defer func() { _ = response.Body.Close() }()
 
 	var buf bytes.Buffer
 	_, err = io.Copy(&buf, response.Body)
@@ -299,33 +273,131 @@ func (u *FluxUpdater) UpdateConfig() error {
 		return err
 	}
 
-	// Clear the old artifact's directory and untar a fresh one.
-	dir := path.Join(u.CacheDir, "latest")
-	if err := os.RemoveAll(dir); err != nil {
-		return err
-	}
-	if err := os.MkdirAll(dir, 0750); err != nil {
+	// Never unpack an artifact the source did not advertise, e.g. a truncated
+	// download.
+	err = verifyDigest(buf.Bytes(), digest)
+	if err != nil {
 		return err
 	}
-	if err = tar.Untar(&buf, dir); err != nil {
+
+	err = u.install(&buf, digest)
+	if err != nil {
 		return err
 	}
 
 	// Update the last archive name, timestamp and url
-	err = os.WriteFile(path.Join(u.CacheDir, cacheLastArchive), []byte(filepath.Base(url)), 0750) // nolint:gosec
+	err = writeFileAtomic(path.Join(u.CacheDir, cacheLastArchive), []byte(filepath.Base(url)))
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path.Join(u.CacheDir, cacheLastArchiveTimestamp), []byte(response.Header.Get("Last-Modified")), 0750) // nolint:gosec
+	err = writeFileAtomic(path.Join(u.CacheDir, cacheLastArchiveTimestamp), []byte(response.Header.Get("Last-Modified")))
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path.Join(u.CacheDir, cacheLastArtifactUrl), []byte(url), 0750) // nolint:gosec
+	err = writeFileAtomic(path.Join(u.CacheDir, cacheLastArtifactUrl), []byte(url))
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// getArtifact returns the URL and the digest of the artifact advertised in the
+// status of the source.
+func (u *FluxUpdater) getArtifact() (url, digest string, err error) {
+	namespace, name, err := splitSource(u.Source)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Get the source from the first Flux Source version the API server serves
+	// it in.
+	var object *unstructured.Unstructured
+	for _, resource := range sourceResources[u.SourceKind] {
+		object, err = u.client.Resource(resource).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return "", "", &ExecutionFailedError{
+				message: fmt.Sprintf("error getting '%s' %s CR: %s", u.Source, u.SourceKind, err),
+			}
+		}
+
+		break
+	}
+
+	if object == nil {
+		return "", "", &ExecutionFailedError{
+			message: fmt.Sprintf("error getting '%s' %s CR", u.Source, u.SourceKind),
+		}
+	}
+
+	responseBytes, err := object.MarshalJSON()
+	if err != nil {
+		return "", "", err
+	}
+
+	// We are not interested in an entire object, we are only interested in getting
+	// some of the status fields that advertise the new archive. They are
+	// the same for all source kinds.
+	type source struct {
+		Status struct {
+			Conditions []metav1.Condition `json:"conditions,omitempty"`
+			Artifact   struct {
+				Url    string
+				Digest string `json:"digest"`
+				// Checksum is the SHA-256 digest advertised by v1beta2
+				// sources.
+				Checksum string `json:"checksum"`
+			}
+		}
+	}
+
+	var src source
+	err = json.Unmarshal(responseBytes, &src)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Note: technically this does not mean an error. An empty field could be a symptom
+	// of the CR still being reconciled, or not being picked up by the Source Controller
+	// at all, in which case, we could simply skip quietly.
+	if src.Status.Artifact.Url == "" {
+		for _, condition := range src.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status != metav1.ConditionTrue {
+				return "", "", &ExecutionFailedError{
+					message: condition.Message,
+				}
+			}
+		}
+
+		return "", "", &ExecutionFailedError{
+			message: fmt.Sprintf("error downloading artifact: got empty URL from %s status", u.SourceKind),
+		}
+	}
+
+	digest = src.Status.Artifact.Digest
+	if digest == "" && src.Status.Artifact.Checksum != "" {
+		digest = digestAlgorithmSHA256 + ":" + src.Status.Artifact.Checksum
+	}
+
+	if digest == "" {
+		return "", "", &ExecutionFailedError{
+			message: fmt.Sprintf("error downloading artifact: got empty digest from %s status", u.SourceKind),
+		}
+	}
+
+	return src.Status.Artifact.Url, digest, nil
+}
+
+// splitSource splits the source reference in the format of <namespace>/<name>.
+func splitSource(source string) (namespace, name string, err error) {
+	namespace, name, found := strings.Cut(source, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", &InvalidConfigError{message: fmt.Sprintf("source %q must be in the format of <namespace>/<name>", source)}
+	}
+
+	return namespace, name, nil
+}
@@ -1,7 +1,16 @@
 package fluxupdater
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -9,6 +18,9 @@ import (
 	"path"
 	"strings"
 	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
 )
 
 const advertisedTimestamp = "Thu, 02 Mar 2024 00:00:00 GMT"
@@ -35,11 +47,22 @@ func TestRunner_updateConfig(t *testing.T) {
 	}))
 	defer srcCtrlServer.Close()
 
+	token, err := os.ReadFile("testdata/token")
+	if err != nil {
+		panic(err)
+	}
+
 	k8sServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch strings.TrimSpace(r.URL.Path) {
 		case "/apis/source.toolkit.fluxcd.io/v1/namespaces/flux-giantswarm/gitrepositories/giantswarm-config":
+			if r.Header.Get("Authorization") != "Bearer "+strings.TrimSpace(string(token)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
-			_, err = w.Write([]byte(`{"status":{"artifact":{"url":"` + srcCtrlServer.URL + "/gitrepository/flux-giantswarm/giantswarm-config/latestrevision.tar.gz" + `"}}}`))
+			_, err = w.Write([]byte(`{"apiVersion":"source.toolkit.fluxcd.io/v1","kind":"GitRepository","metadata":{"name":"giantswarm-config","namespace":"flux-giantswarm"},"status":{"artifact":{"url":"` + srcCtrlServer.URL + "/gitrepository/flux-giantswarm/giantswarm-config/latestrevision.tar.gz" + `","digest":"` + digest(archive) + `"}}}`))
 			if err != nil {
 				panic(err)
 			}
@@ -55,9 +78,13 @@ func TestRunner_updateConfig(t *testing.T) {
 		panic(err)
 	}
 
-	k8sUrl, err := url.Parse(k8sServer.URL)
-	if err != nil {
-		panic(err)
+	// Trust the certificate of the test API server only
+	restConfig := &rest.Config{
+		Host:            k8sServer.URL,
+		BearerTokenFile: "testdata/token",
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k8sServer.Certificate().Raw}),
+		},
 	}
 
 	testCases := []struct {
@@ -137,11 +164,9 @@ func TestRunner_updateConfig(t *testing.T) {
 			}
 
 			fluxUpdaterConfig := Config{
-				CacheDir:            tmpCacheDir,
-				ApiServerHost:       k8sUrl.Hostname(),
-				ApiServerPort:       k8sUrl.Port(),
-				KubernetesTokenFile: "testdata/token",
-				GitRepository:       "flux-giantswarm/giantswarm-config",
+				CacheDir:   tmpCacheDir,
+				RestConfig: restConfig,
+				Source:     "flux-giantswarm/giantswarm-config",
 			}
 
 			fluxUpdater, err := New(fluxUpdaterConfig)
@@ -182,6 +207,335 @@ func TestRunner_updateConfig(t *testing.T) {
 	}
 }
 
+func TestRunner_updateConfigSourceKinds(t *testing.T) {
+	archive, err := os.ReadFile("testdata/latestrevision.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcCtrlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Last-Modified", advertisedTimestamp)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(archive)
+	}))
+	defer srcCtrlServer.Close()
+
+	testCases := []struct {
+		name       string
+		sourceKind string
+		apiPath    string
+	}{
+		{
+			name:       "GitRepository served in v1beta2 only",
+			sourceKind: SourceKindGitRepository,
+			apiPath:    "/apis/source.toolkit.fluxcd.io/v1beta2/namespaces/flux-giantswarm/gitrepositories/giantswarm-config",
+		},
+		{
+			name:       "OCIRepository",
+			sourceKind: SourceKindOCIRepository,
+			apiPath:    "/apis/source.toolkit.fluxcd.io/v1/namespaces/flux-giantswarm/ocirepositories/giantswarm-config",
+		},
+		{
+			name:       "Bucket",
+			sourceKind: SourceKindBucket,
+			apiPath:    "/apis/source.toolkit.fluxcd.io/v1/namespaces/flux-giantswarm/buckets/giantswarm-config",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d: %s", i, tc.name), func(t *testing.T) {
+			k8sServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tc.apiPath {
+					http.NotFoundHandler().ServeHTTP(w, r)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(`{"apiVersion":"source.toolkit.fluxcd.io/v1","kind":"` + tc.sourceKind + `","metadata":{"name":"giantswarm-config","namespace":"flux-giantswarm"},"status":{"artifact":{"url":"` + srcCtrlServer.URL + `/latestrevision.tar.gz","digest":"` + digest(archive) + `"}}}`))
+			}))
+			defer k8sServer.Close()
+
+			fluxUpdater, err := New(Config{
+				CacheDir: t.TempDir(),
+				RestConfig: &rest.Config{
+					Host: k8sServer.URL,
+					TLSClientConfig: rest.TLSClientConfig{
+						CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k8sServer.Certificate().Raw}),
+					},
+				},
+				SourceKind: tc.sourceKind,
+				Source:     "flux-giantswarm/giantswarm-config",
+			})
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err.Error())
+			}
+
+			err = fluxUpdater.UpdateConfig()
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err.Error())
+			}
+
+			config, err := os.ReadFile(path.Join(fluxUpdater.LatestDir(), "config.yaml"))
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err.Error())
+			}
+
+			if strings.TrimSpace(string(config)) != "newvalue" {
+				t.Fatalf("want 'newvalue', got '%s'", string(config))
+			}
+		})
+	}
+}
+
+func TestRunner_updateConfigRevisions(t *testing.T) {
+	var (
+		archive          []byte
+		advertisedDigest string
+		lastModified     time.Time
+	)
+
+	publish := func(value string) {
+		archive = tarGz(t, map[string]string{"config.yaml": value})
+		advertisedDigest = digest(archive)
+		lastModified = lastModified.Add(time.Hour)
+	}
+
+	srcCtrlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(archive)
+	}))
+	defer srcCtrlServer.Close()
+
+	k8sServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"apiVersion":"source.toolkit.fluxcd.io/v1","kind":"OCIRepository","metadata":{"name":"giantswarm-config","namespace":"flux-giantswarm"},"status":{"artifact":{"url":"` + srcCtrlServer.URL + `/latest.tar.gz","digest":"` + advertisedDigest + `"}}}`))
+	}))
+	defer k8sServer.Close()
+
+	cacheDir := t.TempDir()
+
+	// A cache directory of an earlier version with the latest revision
+	// unpacked in place.
+	err := prePopulateCache(cacheDir, []byte(`old.tar.gz`), []byte(`oldvalue`), []byte(`Thu, 28 Feb 2024 00:00:00 GMT`), []byte(srcCtrlServer.URL+"/old.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fluxUpdater, err := New(Config{
+		CacheDir:      cacheDir,
+		KeepRevisions: 1,
+		RestConfig: &rest.Config{
+			Host: k8sServer.URL,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k8sServer.Certificate().Raw}),
+			},
+		},
+		SourceKind: SourceKindOCIRepository,
+		Source:     "flux-giantswarm/giantswarm-config",
+	})
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err.Error())
+	}
+
+	latestValue := func() string {
+		t.Helper()
+
+		config, err := os.ReadFile(path.Join(fluxUpdater.LatestDir(), "config.yaml"))
+		if err != nil {
+			t.Fatalf("want nil, got error: %s", err.Error())
+		}
+
+		return string(config)
+	}
+
+	lastModified = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, value := range []string{"v1", "v2", "v3"} {
+		publish(value)
+
+		err = fluxUpdater.UpdateConfig()
+		if err != nil {
+			t.Fatalf("want nil, got error: %s", err.Error())
+		}
+
+		if latestValue() != value {
+			t.Fatalf("want '%s', got '%s'", value, latestValue())
+		}
+
+		revision, err := fluxUpdater.LatestRevision()
+		if err != nil {
+			t.Fatalf("want nil, got error: %s", err.Error())
+		}
+
+		if revision != strings.ReplaceAll(advertisedDigest, ":", "-") {
+			t.Fatalf("want revision of digest '%s', got '%s'", advertisedDigest, revision)
+		}
+	}
+
+	revisions, err := fluxUpdater.Revisions()
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err.Error())
+	}
+
+	// The latest revision and one previous revision are kept.
+	if len(revisions) != 2 {
+		t.Fatalf("want 2 revisions, got %v", revisions)
+	}
+
+	previous, err := os.ReadFile(path.Join(revisions[1], "config.yaml"))
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err.Error())
+	}
+
+	if string(previous) != "v2" {
+		t.Fatalf("want 'v2', got '%s'", string(previous))
+	}
+
+	// An artifact not matching the advertised digest is never unpacked.
+	publish("v4")
+	advertisedDigest = digest([]byte("something else"))
+
+	err = fluxUpdater.UpdateConfig()
+	if !errors.Is(err, &ExecutionFailedError{}) {
+		t.Fatalf("want ExecutionFailedError, got %v", err)
+	}
+
+	if latestValue() != "v3" {
+		t.Fatalf("want 'v3', got '%s'", latestValue())
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tmpPrefix) {
+			t.Fatalf("want no temporary files, got %s", entry.Name())
+		}
+	}
+}
+
+func TestRunner_updateConfigUntrustedCertificate(t *testing.T) {
+	k8sServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s with an untrusted certificate", r.URL.Path)
+	}))
+	defer k8sServer.Close()
+
+	k8sServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+	fluxUpdater, err := New(Config{
+		CacheDir:   t.TempDir(),
+		RestConfig: &rest.Config{Host: k8sServer.URL},
+		Source:     "flux-giantswarm/giantswarm-config",
+	})
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err.Error())
+	}
+
+	err = fluxUpdater.UpdateConfig()
+	if err == nil {
+		t.Fatalf("want error for a certificate signed by an unknown authority, got nil")
+	}
+}
+
+func TestNew_source(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sourceKind    string
+		source        string
+		expectedError bool
+	}{
+		{
+			name:   "namespace and name",
+			source: "flux-giantswarm/giantswarm-config",
+		},
+		{
+			name:          "missing namespace",
+			source:        "giantswarm-config",
+			expectedError: true,
+		},
+		{
+			name:          "empty name",
+			source:        "flux-giantswarm/",
+			expectedError: true,
+		},
+		{
+			name:       "OCIRepository",
+			sourceKind: SourceKindOCIRepository,
+			source:     "flux-giantswarm/giantswarm-config",
+		},
+		{
+			name:          "unknown source kind",
+			sourceKind:    "HelmRepository",
+			source:        "flux-giantswarm/giantswarm-config",
+			expectedError: true,
+		},
+		{
+			name:          "too many segments",
+			source:        "flux-giantswarm/giantswarm-config/extra",
+			expectedError: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d: %s", i, tc.name), func(t *testing.T) {
+			_, err := New(Config{
+				CacheDir:   t.TempDir(),
+				RestConfig: &rest.Config{Host: "https://localhost"},
+				SourceKind: tc.sourceKind,
+				Source:     tc.source,
+			})
+
+			if tc.expectedError && !errors.Is(err, &InvalidConfigError{}) {
+				t.Fatalf("want InvalidConfigError, got %v", err)
+			}
+			if !tc.expectedError && err != nil {
+				t.Fatalf("want nil, got error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := tw.Close()
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
 func prePopulateCache(cache string, archive, config, timestamp, url []byte) error {
 	var err error
 	if len(timestamp) > 0 {
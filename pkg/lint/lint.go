@@ -0,0 +1,464 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+)
+
+// placeholderPattern matches anything that looks like a variable placeholder,
+// including malformed ones, e.g. `<<stage>>` or `<<  stage >>`.
+var placeholderPattern = regexp.MustCompile(`<<\s*([^<>]*?)\s*>>`)
+
+type Problem struct {
+	// Path is the location of the problem in the schema, e.g. `layers[1].id`.
+	Path string `json:"path"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Schema   string    `json:"schema"`
+	Problems []Problem `json:"problems"`
+
+	// Warnings do not make the schema invalid.
+	Warnings []Problem `json:"warnings"`
+}
+
+// Lint statically validates the schema and returns all the problems found.
+// An empty result means the schema is valid.
+func Lint(schema *model.Schema) []Problem {
+	problems := make([]Problem, 0)
+
+	problems = append(problems, lintLayerIds(schema)...)
+	problems = append(problems, lintIncludes(schema)...)
+	problems = append(problems, lintMergeStrategies(schema)...)
+	problems = append(problems, lintListMergeOptions(schema)...)
+	problems = append(problems, lintPatchTypes(schema)...)
+	problems = append(problems, lintVariables(schema)...)
+
+	return problems
+}
+
+// Warnings returns the findings of a static check that do not make the schema
+// invalid, e.g. variables the schema does not use itself. Templates and
+// includes may still read these through the variable function, which is out
+// of sight of a check of the schema alone.
+func Warnings(schema *model.Schema) []Problem {
+	problems := make([]Problem, 0)
+
+	used := usedVariables(schema)
+	for i, variable := range schema.Variables {
+		if variable.Name != "" && !used[variable.Name] {
+			problems = append(problems, Problem{
+				Path:    fmt.Sprintf("variables[%d].name", i),
+				Message: fmt.Sprintf("variable %q is not used by the schema, only templates and includes can read it through the %s function", variable.Name, renderer.VariableFunctionName),
+			})
+		}
+	}
+
+	return problems
+}
+
+func lintLayerIds(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	seen := make(map[string]int)
+	for i, layer := range schema.Layers {
+		path := fmt.Sprintf("layers[%d].id", i)
+
+		if layer.Id == "" {
+			problems = append(problems, Problem{Path: path, Message: "layer id must not be empty"})
+			continue
+		}
+
+		if first, found := seen[layer.Id]; found {
+			problems = append(problems, Problem{
+				Path:    path,
+				Message: fmt.Sprintf("duplicate layer id %q, first defined at layers[%d].id", layer.Id, first),
+			})
+			continue
+		}
+
+		seen[layer.Id] = i
+	}
+
+	return problems
+}
+
+func lintIncludes(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	seenIds := make(map[string]int)
+	seenFunctions := make(map[string]int)
+	for i, include := range schema.Includes {
+		idPath := fmt.Sprintf("includes[%d].id", i)
+		if include.Id == "" {
+			problems = append(problems, Problem{Path: idPath, Message: "include id must not be empty"})
+		} else if first, found := seenIds[include.Id]; found {
+			problems = append(problems, Problem{
+				Path:    idPath,
+				Message: fmt.Sprintf("duplicate include id %q, first defined at includes[%d].id", include.Id, first),
+			})
+		} else {
+			seenIds[include.Id] = i
+		}
+
+		functionPath := fmt.Sprintf("includes[%d].function.name", i)
+		if include.Function.Name == "" {
+			problems = append(problems, Problem{Path: functionPath, Message: "include function name must not be empty"})
+		} else if include.Function.Name == renderer.VariableFunctionName {
+			problems = append(problems, Problem{
+				Path:    functionPath,
+				Message: fmt.Sprintf("include function name %q is reserved", include.Function.Name),
+			})
+		} else if first, found := seenFunctions[include.Function.Name]; found {
+			problems = append(problems, Problem{
+				Path:    functionPath,
+				Message: fmt.Sprintf("duplicate include function name %q, first defined at includes[%d].function.name", include.Function.Name, first),
+			})
+		} else {
+			seenFunctions[include.Function.Name] = i
+		}
+	}
+
+	return problems
+}
+
+func lintMergeStrategies(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	layerIds := make(map[string]bool)
+	for _, layer := range schema.Layers {
+		layerIds[layer.Id] = true
+	}
+
+	for i, layer := range schema.Layers {
+		templates := map[string]model.Template{
+			"configMap": layer.Templates.ConfigMap,
+			"secret":    layer.Templates.Secret,
+		}
+
+		for _, templateType := range []string{"configMap", "secret"} {
+			merge := templates[templateType].Values.Merge
+			path := fmt.Sprintf("layers[%d].templates.%s.values.merge", i, templateType)
+
+			if !isKnownMergeStrategy(merge.Strategy) {
+				problems = append(problems, Problem{
+					Path:    path + ".strategy",
+					Message: fmt.Sprintf("unknown value merge strategy %q, must be one of: %s", merge.Strategy, strings.Join(model.ValueFileMergeStrategies, ",")),
+				})
+				continue
+			}
+
+			if !strings.EqualFold(merge.Strategy, model.ValueFileMergeStrategyCustomOrder) {
+				continue
+			}
+
+			options := model.CustomOrderValueMergeStrategyOptions{}
+			err := merge.Options.Unmarshal(&options)
+			if err != nil {
+				problems = append(problems, Problem{
+					Path:    path + ".options",
+					Message: fmt.Sprintf("invalid %s options: %s", model.ValueFileMergeStrategyCustomOrder, err),
+				})
+				continue
+			}
+
+			for j, reference := range options.Order {
+				referencePath := fmt.Sprintf("%s.options.order[%d]", path, j)
+
+				if !layerIds[reference.LayerId] {
+					problems = append(problems, Problem{
+						Path:    referencePath + ".layerId",
+						Message: fmt.Sprintf("unknown layer id %q", reference.LayerId),
+					})
+				}
+
+				if !strings.EqualFold(string(reference.Type), string(model.ValueMergeReferenceTypeConfigMap)) &&
+					!strings.EqualFold(string(reference.Type), string(model.ValueMergeReferenceTypeSecret)) {
+					problems = append(problems, Problem{
+						Path: referencePath + ".type",
+						Message: fmt.Sprintf(
+							"unknown value merge reference type %q, must be one of: %s,%s",
+							reference.Type, model.ValueMergeReferenceTypeConfigMap, model.ValueMergeReferenceTypeSecret,
+						),
+					})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+func lintListMergeOptions(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	for i, layer := range schema.Layers {
+		lists := layer.Merge.Lists
+		path := fmt.Sprintf("layers[%d].merge.lists", i)
+
+		err := renderer.ValidateListMergeStrategy(lists.Strategy, lists.Key)
+		if err != nil {
+			problems = append(problems, Problem{Path: path + ".strategy", Message: err.Error()})
+		}
+
+		seen := make(map[string]int)
+		for j, listPath := range lists.Paths {
+			entryPath := fmt.Sprintf("%s.paths[%d]", path, j)
+
+			if listPath.Path == "" {
+				problems = append(problems, Problem{Path: entryPath + ".path", Message: "list merge path must not be empty"})
+			} else if first, found := seen[listPath.Path]; found {
+				problems = append(problems, Problem{
+					Path:    entryPath + ".path",
+					Message: fmt.Sprintf("duplicate list merge path %q, first defined at %s.paths[%d].path", listPath.Path, path, first),
+				})
+			} else {
+				seen[listPath.Path] = j
+			}
+
+			err = renderer.ValidateListMergeStrategy(listPath.Strategy, listPath.Key)
+			if err != nil {
+				problems = append(problems, Problem{Path: entryPath + ".strategy", Message: err.Error()})
+			}
+		}
+	}
+
+	return problems
+}
+
+func lintPatchTypes(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	for i, layer := range schema.Layers {
+		patches := map[string]model.PatchOptions{
+			"configMap": layer.Patches.ConfigMap,
+			"secret":    layer.Patches.Secret,
+		}
+
+		for _, patchType := range []string{"configMap", "secret"} {
+			options := patches[patchType]
+
+			if options.Type != "" && !slices.ContainsFunc(model.PatchTypes, func(known string) bool {
+				return strings.EqualFold(known, options.Type)
+			}) {
+				problems = append(problems, Problem{
+					Path:    fmt.Sprintf("layers[%d].patches.%s.type", i, patchType),
+					Message: fmt.Sprintf("unknown patch type %q, must be one of: %s", options.Type, strings.Join(model.PatchTypes, ",")),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+func isKnownMergeStrategy(strategy string) bool {
+	// Empty strategy defaults to SameTypeFromCurrentLayer.
+	if strategy == "" {
+		return true
+	}
+
+	for _, known := range model.ValueFileMergeStrategies {
+		if strings.EqualFold(strategy, known) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lintVariables(schema *model.Schema) []Problem {
+	var problems []Problem
+
+	declared := make(map[string]bool)
+	for i, variable := range schema.Variables {
+		path := fmt.Sprintf("variables[%d].name", i)
+
+		if variable.Name == "" {
+			problems = append(problems, Problem{Path: path, Message: "variable name must not be empty"})
+			continue
+		}
+
+		if declared[variable.Name] {
+			problems = append(problems, Problem{Path: path, Message: fmt.Sprintf("duplicate variable %q", variable.Name)})
+			continue
+		}
+
+		declared[variable.Name] = true
+
+		if variable.Pattern != "" {
+			_, err := regexp.Compile(variable.Pattern)
+			if err != nil {
+				problems = append(problems, Problem{
+					Path:    fmt.Sprintf("variables[%d].pattern", i),
+					Message: fmt.Sprintf("invalid pattern %q: %s", variable.Pattern, err),
+				})
+				continue
+			}
+		}
+
+		if variable.Default != "" {
+			err := renderer.ValidateVariableValue(variable, variable.Default)
+			if err != nil {
+				problems = append(problems, Problem{
+					Path:    fmt.Sprintf("variables[%d].default", i),
+					Message: fmt.Sprintf("invalid default value: %s", err),
+				})
+			}
+		}
+	}
+
+	for i, variable := range schema.Variables {
+		if variable.Value == "" {
+			continue
+		}
+
+		path := fmt.Sprintf("variables[%d]", i)
+
+		if variable.Required || variable.Default != "" {
+			problems = append(problems, Problem{
+				Path:    path + ".value",
+				Message: fmt.Sprintf("derived variable %q must not be required or have a default", variable.Name),
+			})
+		}
+
+		dependencies, err := renderer.ExpressionVariables(variable.Value)
+		if err != nil {
+			problems = append(problems, Problem{
+				Path:    path + ".value",
+				Message: fmt.Sprintf("invalid value expression: %s", err),
+			})
+			continue
+		}
+
+		for _, dependency := range dependencies {
+			if !declared[dependency] {
+				problems = append(problems, Problem{
+					Path:    path + ".value",
+					Message: fmt.Sprintf("value expression references undeclared variable %q", dependency),
+				})
+			}
+		}
+	}
+
+	for i, layer := range schema.Layers {
+		if layer.When != "" {
+			dependencies, err := renderer.ExpressionVariables(layer.When)
+			if err != nil {
+				problems = append(problems, Problem{
+					Path:    fmt.Sprintf("layers[%d].when", i),
+					Message: fmt.Sprintf("invalid condition: %s", err),
+				})
+			}
+
+			for _, dependency := range dependencies {
+				if !declared[dependency] {
+					problems = append(problems, Problem{
+						Path:    fmt.Sprintf("layers[%d].when", i),
+						Message: fmt.Sprintf("condition references undeclared variable %q", dependency),
+					})
+				}
+			}
+		}
+
+		for _, field := range substitutedLayerFields(layer) {
+			path := fmt.Sprintf("layers[%d].%s", i, field.path)
+
+			for _, match := range placeholderPattern.FindAllStringSubmatch(field.value, -1) {
+				name := match[1]
+
+				if match[0] != fmt.Sprintf("<< %s >>", name) {
+					problems = append(problems, Problem{
+						Path:    path,
+						Message: fmt.Sprintf("malformed placeholder %q, expected %q", match[0], fmt.Sprintf("<< %s >>", name)),
+					})
+				}
+
+				if !declared[name] {
+					problems = append(problems, Problem{
+						Path:    path,
+						Message: fmt.Sprintf("placeholder %q references undeclared variable %q", match[0], name),
+					})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// usedVariables returns the names of the variables the schema itself uses in
+// value expressions, `when` conditions and placeholders.
+func usedVariables(schema *model.Schema) map[string]bool {
+	var expressions []string
+	for _, variable := range schema.Variables {
+		if variable.Value != "" {
+			expressions = append(expressions, variable.Value)
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, layer := range schema.Layers {
+		if layer.When != "" {
+			expressions = append(expressions, layer.When)
+		}
+
+		for _, field := range substitutedLayerFields(layer) {
+			for _, match := range placeholderPattern.FindAllStringSubmatch(field.value, -1) {
+				used[match[1]] = true
+			}
+		}
+	}
+
+	for _, expression := range expressions {
+		// Invalid expressions are reported by Lint.
+		names, _ := renderer.ExpressionVariables(expression)
+		for _, name := range names {
+			used[name] = true
+		}
+	}
+
+	return used
+}
+
+type layerField struct {
+	path  string
+	value string
+}
+
+// substitutedLayerFields returns all fields of the layer that are subject to
+// variable substitution, with their path relative to the layer.
+func substitutedLayerFields(layer model.Layer) []layerField {
+	fields := []layerField{
+		{"path.directory", layer.Path.Directory},
+		{"values.path.directory", layer.Values.Path.Directory},
+		{"values.configMap.name", layer.Values.ConfigMap.Name},
+	}
+
+	for i, name := range layer.Values.ConfigMap.Names {
+		fields = append(fields, layerField{fmt.Sprintf("values.configMap.names[%d]", i), name})
+	}
+
+	fields = append(fields, layerField{"values.secret.name", layer.Values.Secret.Name})
+
+	for i, name := range layer.Values.Secret.Names {
+		fields = append(fields, layerField{fmt.Sprintf("values.secret.names[%d]", i), name})
+	}
+
+	return append(fields,
+		layerField{"templates.path.directory", layer.Templates.Path.Directory},
+		layerField{"templates.configMap.name", layer.Templates.ConfigMap.Name},
+		layerField{"templates.secret.name", layer.Templates.Secret.Name},
+		layerField{"patches.path.directory", layer.Patches.Path.Directory},
+		layerField{"patches.configMap.name", layer.Patches.ConfigMap.Name},
+		layerField{"patches.secret.name", layer.Patches.Secret.Name},
+	)
+}
@@ -0,0 +1,255 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		schema *model.Schema
+
+		expected []Problem
+	}{
+		{
+			name:     "case 0 - empty schema",
+			schema:   &model.Schema{},
+			expected: []Problem{},
+		},
+		{
+			name: "case 1 - valid schema",
+			schema: &model.Schema{
+				Variables: []model.Variable{
+					{Name: "stage", Required: true},
+				},
+				Layers: []model.Layer{
+					{
+						Id:   "base",
+						Path: model.Path{Directory: "base"},
+					},
+					{
+						Id:   "stages",
+						Path: model.Path{Directory: "stages/<< stage >>"},
+						Templates: model.Templates{
+							ConfigMap: model.Template{
+								Name: "template.yaml",
+								Values: model.ValueFileOptions{
+									Merge: model.ValueFileMergeOptions{Strategy: model.ValueFileMergeStrategyConfigMapsInLayerOrder},
+								},
+							},
+						},
+					},
+				},
+				Includes: []model.Include{
+					{Id: "include", Function: model.IncludeFunction{Name: "include"}},
+				},
+			},
+			expected: []Problem{},
+		},
+		{
+			name: "case 2 - duplicate ids and function names",
+			schema: &model.Schema{
+				Layers: []model.Layer{
+					{Id: "base"},
+					{Id: "base"},
+					{},
+				},
+				Includes: []model.Include{
+					{Id: "a", Function: model.IncludeFunction{Name: "include"}},
+					{Id: "b", Function: model.IncludeFunction{Name: "include"}},
+				},
+			},
+			expected: []Problem{
+				{Path: "layers[1].id", Message: `duplicate layer id "base", first defined at layers[0].id`},
+				{Path: "layers[2].id", Message: "layer id must not be empty"},
+				{Path: "includes[1].function.name", Message: `duplicate include function name "include", first defined at includes[0].function.name`},
+			},
+		},
+		{
+			name: "case 3 - unknown merge strategy",
+			schema: &model.Schema{
+				Layers: []model.Layer{
+					{
+						Id: "base",
+						Templates: model.Templates{
+							Secret: model.Template{
+								Values: model.ValueFileOptions{
+									Merge: model.ValueFileMergeOptions{Strategy: "Whatever"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []Problem{
+				{
+					Path:    "layers[0].templates.secret.values.merge.strategy",
+					Message: `unknown value merge strategy "Whatever", must be one of: CustomOrder,SameTypeInLayerOrder,ConfigMapsInLayerOrder,SecretsInLayerOrder,ConfigMapsAndSecretsInLayerOrder,SameTypeFromCurrentLayer,ConfigMapAndSecretFromCurrentLayer`,
+				},
+			},
+		},
+		{
+			name: "case 4 - undeclared and malformed variables",
+			schema: &model.Schema{
+				Variables: []model.Variable{
+					{Name: "stage"},
+					{Name: "cluster"},
+				},
+				Layers: []model.Layer{
+					{
+						Id:   "base",
+						Path: model.Path{Directory: "<<stage>>/<< app >>"},
+					},
+				},
+			},
+			expected: []Problem{
+				{Path: "layers[0].path.directory", Message: `malformed placeholder "<<stage>>", expected "<< stage >>"`},
+				{Path: "layers[0].path.directory", Message: `placeholder "<< app >>" references undeclared variable "app"`},
+			},
+		},
+		{
+			name: "case 5 - invalid pattern and default value",
+			schema: &model.Schema{
+				Variables: []model.Variable{
+					{Name: "stage", Default: "prod", Enum: []string{"dev", "production"}},
+					{Name: "cluster", Pattern: "[a-z"},
+				},
+				Layers: []model.Layer{
+					{
+						Id:   "base",
+						Path: model.Path{Directory: "<< stage >>/<< cluster >>"},
+					},
+				},
+			},
+			expected: []Problem{
+				{Path: "variables[0].default", Message: `invalid default value: invalid value "prod" for variable stage: must be one of: dev,production`},
+				{Path: "variables[1].pattern", Message: "invalid pattern \"[a-z\": error parsing regexp: missing closing ]: `[a-z`"},
+			},
+		},
+		{
+			name: "case 6 - invalid list merge options",
+			schema: &model.Schema{
+				Layers: []model.Layer{
+					{
+						Id: "base",
+						Merge: model.LayerMergeOptions{
+							Lists: model.ListMergeOptions{
+								Strategy: model.ListMergeStrategyMergeByKey,
+								Paths: []model.ListMergePath{
+									{Path: "apps", Strategy: model.ListMergeStrategyAppend},
+									{Path: "apps", Strategy: "Whatever"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []Problem{
+				{Path: "layers[0].merge.lists.strategy", Message: "list merge strategy MergeByKey requires a key"},
+				{Path: "layers[0].merge.lists.paths[1].path", Message: `duplicate list merge path "apps", first defined at layers[0].merge.lists.paths[0].path`},
+				{Path: "layers[0].merge.lists.paths[1].strategy", Message: `unknown list merge strategy "Whatever", must be one of: Replace,Append,Prepend,MergeByKey`},
+			},
+		},
+		{
+			name: "case 7 - unknown patch type",
+			schema: &model.Schema{
+				Layers: []model.Layer{
+					{
+						Id: "base",
+						Patches: model.Patches{
+							ConfigMap: model.PatchOptions{Type: "strategicmerge"},
+							Secret:    model.PatchOptions{Type: "Strategic"},
+						},
+					},
+				},
+			},
+			expected: []Problem{
+				{Path: "layers[0].patches.secret.type", Message: `unknown patch type "Strategic", must be one of: JSON6902,Merge,StrategicMerge`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := Lint(tc.schema)
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		schema *model.Schema
+
+		expected []Problem
+	}{
+		{
+			name: "case 0 - variables used by the schema",
+			schema: &model.Schema{
+				Variables: []model.Variable{
+					{Name: "stage"},
+					{Name: "cluster"},
+					{Name: "region"},
+					{Name: "prefix", Value: `{{ .region }}-x`},
+				},
+				Layers: []model.Layer{
+					{
+						Id:   "stages",
+						Path: model.Path{Directory: "stages/<< stage >>"},
+						When: `{{ eq (variable "cluster") "mc-1" }}`,
+						Values: model.Values{
+							ConfigMap: model.Value{Name: "<< prefix >>.yaml"},
+						},
+					},
+				},
+			},
+			expected: []Problem{},
+		},
+		{
+			// The template of the layer reads the variable with
+			// `{{ variable "region" }}`, which is out of sight of the schema.
+			name: "case 1 - variable only used by a template",
+			schema: &model.Schema{
+				Variables: []model.Variable{
+					{Name: "stage"},
+					{Name: "region"},
+				},
+				Layers: []model.Layer{
+					{
+						Id:   "stages",
+						Path: model.Path{Directory: "stages/<< stage >>"},
+						Templates: model.Templates{
+							ConfigMap: model.Template{Name: "template.yaml"},
+						},
+					},
+				},
+			},
+			expected: []Problem{
+				{Path: "variables[1].name", Message: `variable "region" is not used by the schema, only templates and includes can read it through the variable function`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if problems := Lint(tc.schema); len(problems) != 0 {
+				t.Fatalf("Expected no problems, got %v", problems)
+			}
+
+			result := Warnings(tc.schema)
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
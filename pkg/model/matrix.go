@@ -0,0 +1,21 @@
+package model
+
+// RenderMatrix lists the variable sets a schema is rendered with in one run of
+// the `render-all` command.
+type RenderMatrix struct {
+	// Variables are shared by all entries, entries can override them.
+	Variables map[string]string   `yaml:"variables"`
+	Entries   []RenderMatrixEntry `yaml:"entries"`
+}
+
+type RenderMatrixEntry struct {
+	// The name and namespace of the rendered ConfigMap and Secret.
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+
+	// Output is the path of the file the result is written to, relative to
+	// the output directory. Defaults to `<name>.yaml`.
+	Output string `yaml:"output"`
+
+	Variables map[string]string `yaml:"variables"`
+}
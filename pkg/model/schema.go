@@ -1,23 +1,90 @@
 package model
 
+const (
+	// SchemaAPIVersionV1 is the current version of the schema format. Schemas
+	// without an `apiVersion` are considered legacy and are migrated to it
+	// when loaded.
+	SchemaAPIVersionV1 = "konfigure.giantswarm.io/v1"
+	SchemaKind         = "KonfigurationSchema"
+)
+
 type Schema struct {
-	Variables []Variable `yaml:"variables"`
-	Layers    []Layer    `yaml:"layers"`
-	Includes  []Include  `yaml:"includes"`
+	APIVersion string     `yaml:"apiVersion,omitempty"`
+	Kind       string     `yaml:"kind,omitempty"`
+	Extends    string     `yaml:"extends,omitempty"`
+	Variables  []Variable `yaml:"variables"`
+	Layers     []Layer    `yaml:"layers"`
+	Includes   []Include  `yaml:"includes"`
 }
 
 type Variable struct {
-	Name     string `yaml:"name"`
-	Required bool   `yaml:"required"`
-	Default  string `yaml:"default"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Required    bool     `yaml:"required"`
+	Default     string   `yaml:"default"`
+	Enum        []string `yaml:"enum"`
+	Pattern     string   `yaml:"pattern"`
+
+	// Value makes the variable derived, it is a Go template evaluated
+	// against the other variables.
+	Value string `yaml:"value"`
 }
 
 type Layer struct {
 	Id        string    `yaml:"id"`
+	When      string    `yaml:"when"`
 	Path      Path      `yaml:"path"`
 	Values    Values    `yaml:"values"`
 	Templates Templates `yaml:"templates"`
 	Patches   Patches   `yaml:"patches"`
+
+	// Merge configures how the documents of the layer are merged on top of
+	// the documents of the other layers.
+	Merge LayerMergeOptions `yaml:"merge"`
+
+	// Position places the layer relative to a layer of the extended schema.
+	Position LayerPosition `yaml:"position,omitempty"`
+}
+
+type LayerPosition struct {
+	Before string `yaml:"before,omitempty"`
+	After  string `yaml:"after,omitempty"`
+}
+
+type LayerMergeOptions struct {
+	Lists ListMergeOptions `yaml:"lists"`
+}
+
+const (
+	ListMergeStrategyReplace    = "Replace"
+	ListMergeStrategyAppend     = "Append"
+	ListMergeStrategyPrepend    = "Prepend"
+	ListMergeStrategyMergeByKey = "MergeByKey"
+)
+
+// ListMergeStrategies lists all supported list merge strategies.
+var ListMergeStrategies = []string{
+	ListMergeStrategyReplace,
+	ListMergeStrategyAppend,
+	ListMergeStrategyPrepend,
+	ListMergeStrategyMergeByKey,
+}
+
+// ListMergeOptions configures how lists are merged. Strategy and Key apply to
+// all lists, unless overridden for the list at a given path.
+type ListMergeOptions struct {
+	Strategy string          `yaml:"strategy"`
+	Key      string          `yaml:"key"`
+	Paths    []ListMergePath `yaml:"paths"`
+}
+
+// ListMergePath overrides the list merge strategy for the list at Path, a dot
+// separated list of keys, e.g. `ingress.hosts`. Lists nested in list items are
+// addressed without index, e.g. `apps.env` for the `env` list of `apps` items.
+type ListMergePath struct {
+	Path     string `yaml:"path"`
+	Strategy string `yaml:"strategy"`
+	Key      string `yaml:"key"`
 }
 
 type Values struct {
@@ -27,8 +94,21 @@ type Values struct {
 }
 
 type Value struct {
-	Name     string `yaml:"name"`
-	Required bool   `yaml:"required"`
+	// Name and Names can be glob patterns, matching files are merged in
+	// lexical order, after the files of the preceding names.
+	Name     string   `yaml:"name"`
+	Names    []string `yaml:"names,omitempty"`
+	Required bool     `yaml:"required"`
+}
+
+// Patterns returns the names of the value files in the order they are merged.
+func (v Value) Patterns() []string {
+	var patterns []string
+	if v.Name != "" {
+		patterns = append(patterns, v.Name)
+	}
+
+	return append(patterns, v.Names...)
 }
 
 type Templates struct {
@@ -57,6 +137,17 @@ const (
 	ValueFileMergeStrategyConfigMapAndSecretFromCurrentLayer = "ConfigMapAndSecretFromCurrentLayer" // nolint:gosec
 )
 
+// ValueFileMergeStrategies lists all supported value file merge strategies.
+var ValueFileMergeStrategies = []string{
+	ValueFileMergeStrategyCustomOrder,
+	ValueFileMergeStrategySameTypeInLayerOrder,
+	ValueFileMergeStrategyConfigMapsInLayerOrder,
+	ValueFileMergeStrategySecretsInLayerOrder,
+	ValueFileMergeStrategyConfigMapsAndSecretsInLayerOrder,
+	ValueFileMergeStrategySameTypeFromCurrentLayer,
+	ValueFileMergeStrategyConfigMapAndSecretFromCurrentLayer,
+}
+
 type RawMessage struct {
 	unmarshal func(interface{}) error
 }
@@ -67,6 +158,11 @@ func (msg *RawMessage) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func (msg *RawMessage) Unmarshal(v interface{}) error {
+	// Options were not set at all, leave v untouched.
+	if msg.unmarshal == nil {
+		return nil
+	}
+
 	return msg.unmarshal(v)
 }
 
@@ -100,6 +196,22 @@ type Patches struct {
 type PatchOptions struct {
 	Name     string `yaml:"name"`
 	Required bool   `yaml:"required"`
+
+	// Type is the format of the patch file, JSON6902 by default.
+	Type string `yaml:"type"`
+}
+
+const (
+	PatchTypeJSON6902       = "JSON6902"
+	PatchTypeMerge          = "Merge"
+	PatchTypeStrategicMerge = "StrategicMerge"
+)
+
+// PatchTypes lists all supported patch types.
+var PatchTypes = []string{
+	PatchTypeJSON6902,
+	PatchTypeMerge,
+	PatchTypeStrategicMerge,
 }
 
 type Include struct {
@@ -0,0 +1,135 @@
+package renderer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	sopsV3Decrypt "github.com/getsops/sops/v3/decrypt"
+)
+
+// Cache keeps decrypted SOPS files and parsed templates, so rendering a schema
+// many times in one process, e.g. for every entry of a render matrix, decrypts
+// and parses every file only once. Entries are keyed by the path and the
+// SHA-256 hash of the content of the file, so changed files are never served
+// from the cache. The cache is safe for concurrent use and a nil Cache does not
+// cache anything.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// load returns the cached value for the key, or loads it. Concurrent loads of
+// the same key wait for the first one. Failed loads are not cached.
+func (c *Cache) load(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	if !found {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = load()
+	})
+
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+
+	return entry.value, entry.err
+}
+
+// decrypt decrypts the content of the SOPS encrypted YAML file at path.
+func (c *Cache) decrypt(path string, content []byte) ([]byte, error) {
+	if c == nil {
+		return sopsV3Decrypt.Data(content, "yaml")
+	}
+
+	value, err := c.load(cacheKey("decrypt", path, string(content)), func() (interface{}, error) {
+		return sopsV3Decrypt.Data(content, "yaml")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.([]byte), nil
+}
+
+// parseTemplate parses the template text of the file at path. Template
+// functions close over the variables of a render, so cached templates are
+// cloned and bound to the given functions.
+func (c *Cache) parseTemplate(path, name, text string, functions template.FuncMap) (*template.Template, error) {
+	parse := func() (*template.Template, error) {
+		return template.New(name).Funcs(functions).Option("missingkey=error").Parse(text)
+	}
+
+	if c == nil {
+		return parse()
+	}
+
+	// Parsing only succeeds with the functions used by the template defined,
+	// so templates parsed with different function names are kept apart.
+	names := make([]string, 0, len(functions))
+	for functionName := range functions {
+		names = append(names, functionName)
+	}
+	sort.Strings(names)
+
+	value, err := c.load(cacheKey("template", path, name, text, strings.Join(names, ",")), func() (interface{}, error) {
+		return parse()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clone, err := value.(*template.Template).Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return clone.Funcs(functions), nil
+}
+
+func cacheKey(kind, path string, contents ...string) string {
+	hash := sha256.New()
+	for _, content := range contents {
+		hash.Write([]byte(content))
+		hash.Write([]byte{0})
+	}
+
+	return kind + ":" + filepath.Clean(path) + ":" + hex.EncodeToString(hash.Sum(nil))
+}
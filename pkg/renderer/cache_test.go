@@ -0,0 +1,110 @@
+package renderer
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"text/template"
+)
+
+func TestCache_parseTemplate(t *testing.T) {
+	cache := NewCache()
+
+	render := func(text, stage string) string {
+		t.Helper()
+
+		functions := template.FuncMap{
+			VariableFunctionName: generateVariableFunction(SchemaVariables{"stage": stage}),
+		}
+
+		parsed, err := cache.parseTemplate("template.yaml", "main", text, functions)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		out := new(bytes.Buffer)
+		err = parsed.Execute(out, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return out.String()
+	}
+
+	text := `stage: {{ variable "stage" }}`
+
+	if out := render(text, "dev"); out != "stage: dev" {
+		t.Fatalf("out = %q, want %q", out, "stage: dev")
+	}
+
+	// Cached templates are bound to the functions of every render.
+	if out := render(text, "production"); out != "stage: production" {
+		t.Fatalf("out = %q, want %q", out, "stage: production")
+	}
+
+	if cache.Len() != 1 {
+		t.Fatalf("expected 1 cached template, got %d", cache.Len())
+	}
+
+	// Changed content of the same file is parsed again.
+	if out := render("changed: "+text, "dev"); out != "changed: stage: dev" {
+		t.Fatalf("out = %q, want %q", out, "changed: stage: dev")
+	}
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached templates, got %d", cache.Len())
+	}
+}
+
+func TestCache_load(t *testing.T) {
+	cache := NewCache()
+
+	var loads int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := cache.load("key", func() (interface{}, error) {
+				mu.Lock()
+				loads++
+				mu.Unlock()
+				return "value", nil
+			})
+			if err != nil || value != "value" {
+				t.Errorf("value = %v, err = %v", value, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if loads != 1 {
+		t.Fatalf("expected 1 load, got %d", loads)
+	}
+
+	// Failed loads are retried.
+	for i := 0; i < 2; i++ {
+		_, err := cache.load("failing", func() (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+
+	if cache.Len() != 1 {
+		t.Fatalf("expected only the successful load to be cached, got %d entries", cache.Len())
+	}
+
+	var nilCache *Cache
+
+	parsed, err := nilCache.parseTemplate("template.yaml", "main", "a: 1", template.FuncMap{})
+	if err != nil || parsed == nil {
+		t.Fatalf("expected a nil cache to parse the template, got %v, %v", parsed, err)
+	}
+}
@@ -0,0 +1,318 @@
+package renderer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml3 "gopkg.in/yaml.v3"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+// schemaFiles reads schema files either from the OS filesystem or from an
+// fs.FS.
+type schemaFiles interface {
+	readFile(name string) ([]byte, error)
+
+	// extended returns the path of the schema extended by the schema at name.
+	// The extended schema is relative to the extending one.
+	extended(name, extends string) string
+
+	// id identifies the schema file at name to detect cycles.
+	id(name string) (string, error)
+}
+
+type osSchemaFiles struct{}
+
+func (osSchemaFiles) readFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Clean(name))
+}
+
+func (osSchemaFiles) extended(name, extends string) string {
+	return filepath.Join(filepath.Dir(name), extends)
+}
+
+func (osSchemaFiles) id(name string) (string, error) {
+	return filepath.Abs(name)
+}
+
+type fsSchemaFiles struct {
+	fsys fs.FS
+}
+
+func (f fsSchemaFiles) readFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+func (fsSchemaFiles) extended(name, extends string) string {
+	return path.Join(path.Dir(name), extends)
+}
+
+func (fsSchemaFiles) id(name string) (string, error) {
+	return path.Clean(name), nil
+}
+
+// recordingSchemaFiles records the paths of the schema files read.
+type recordingSchemaFiles struct {
+	schemaFiles
+	paths []string
+}
+
+func (r *recordingSchemaFiles) readFile(name string) ([]byte, error) {
+	r.paths = append(r.paths, name)
+	return r.schemaFiles.readFile(name)
+}
+
+// SchemaFiles returns the paths of the schema file at path and of the schemas
+// it extends, the extending schemas first. Paths of schemas that failed to
+// load are returned along with the error, so changes fixing them can be
+// watched for.
+func SchemaFiles(path string) ([]string, error) {
+	files := &recordingSchemaFiles{schemaFiles: osSchemaFiles{}}
+
+	_, err := loadSchemaDocument(files, path, nil)
+
+	return files.paths, err
+}
+
+// loadSchemaDocument reads the schema file, migrates it to the current schema
+// version, validates it and resolves the schema it extends, if any. The result
+// is a single flattened schema document. The chain holds the ids of the
+// extending schemas to detect cycles.
+func loadSchemaDocument(files schemaFiles, path string, chain []string) (*yaml3.Node, error) {
+	content, err := files.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml3.Node
+	if err := yaml3.Unmarshal(content, &document); err != nil {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	// Empty schema, handle it like an empty mapping.
+	if document.Kind == 0 {
+		document = yaml3.Node{
+			Kind:    yaml3.DocumentNode,
+			Content: []*yaml3.Node{{Kind: yaml3.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	// Migrating a legacy schema pins defaults that would override the fields
+	// of the extended schema, so composition requires a versioned schema.
+	if root := document.Content[0]; scalarValue(root, "extends") != "" && scalarValue(root, "apiVersion") == legacySchemaAPIVersion {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: extending schemas must set apiVersion %q", path, model.SchemaAPIVersionV1)}
+	}
+
+	// Older schema versions are migrated to the current one before decoding,
+	// so the rest of the renderer only ever deals with the current version.
+	if _, err := migrateSchemaNode(&document); err != nil {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	// Validate each file on its own and as written, so errors point to the
+	// right file and line. Migrations only add fields the schema knows.
+	if err := decodeStrict(path, content, &model.Schema{}); err != nil {
+		return nil, err
+	}
+
+	var schema model.Schema
+	if err := document.Decode(&schema); err != nil {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	root := document.Content[0]
+
+	if schema.Extends == "" {
+		for i, layer := range schema.Layers {
+			if layer.Position.Before != "" || layer.Position.After != "" {
+				return nil, &InvalidSchemaError{message: fmt.Sprintf(
+					"%s: layers[%d].position is only supported in schemas extending another schema", path, i,
+				)}
+			}
+		}
+
+		return &document, nil
+	}
+
+	id, err := files.id(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extending := range chain {
+		if extending == id {
+			return nil, &InvalidSchemaError{message: fmt.Sprintf(
+				"schemas extend each other in a cycle: %s", strings.Join(append(chain, id), " -> "),
+			)}
+		}
+	}
+
+	parent, err := loadSchemaDocument(files, files.extended(path, schema.Extends), append(chain, id))
+	if err != nil {
+		return nil, err
+	}
+
+	composed, err := composeSchemaNodes(parent.Content[0], root)
+	if err != nil {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	document.Content[0] = composed
+
+	return &document, nil
+}
+
+// composeSchemaNodes merges the extending schema on top of the extended one.
+// Variables and includes are merged by name and id respectively, layers are
+// merged by id and can be placed before or after a layer of the extended
+// schema. Fields of merged entries are overridden individually.
+func composeSchemaNodes(parent, child *yaml3.Node) (*yaml3.Node, error) {
+	result := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		result.Content = append(result.Content, parent.Content[i], parent.Content[i+1])
+	}
+
+	for i := 0; i+1 < len(child.Content); i += 2 {
+		key, value := child.Content[i], child.Content[i+1]
+
+		var err error
+		switch key.Value {
+		case "extends":
+			continue
+		case "variables":
+			value, err = composeSequenceNodes(mappingValue(parent, key.Value), value, "name", false)
+		case "includes":
+			value, err = composeSequenceNodes(mappingValue(parent, key.Value), value, "id", false)
+		case "layers":
+			value, err = composeSequenceNodes(mappingValue(parent, key.Value), value, "id", true)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		replaced := false
+		for j := 0; j+1 < len(result.Content); j += 2 {
+			if result.Content[j].Value == key.Value {
+				result.Content[j+1] = value
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			result.Content = append(result.Content, key, value)
+		}
+	}
+
+	return result, nil
+}
+
+// composeSequenceNodes merges the child sequence on top of the parent sequence
+// using the given key field to identify entries. Entries of the child that are
+// not in the parent are appended, unless positioning is enabled and the entry
+// has a `position` with either `before` or `after` set to a key in the result.
+func composeSequenceNodes(parent, child *yaml3.Node, key string, positioning bool) (*yaml3.Node, error) {
+	result := &yaml3.Node{Kind: yaml3.SequenceNode, Tag: "!!seq"}
+
+	if parent != nil && parent.Kind == yaml3.SequenceNode {
+		result.Content = append(result.Content, parent.Content...)
+	}
+
+	if child == nil || child.Kind != yaml3.SequenceNode {
+		return result, nil
+	}
+
+	indexOf := func(id string) int {
+		for i, entry := range result.Content {
+			if scalarValue(entry, key) == id {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	for _, entry := range child.Content {
+		id := scalarValue(entry, key)
+
+		var before, after string
+		if positioning {
+			position := mappingValue(entry, "position")
+			before, after = scalarValue(position, "before"), scalarValue(position, "after")
+
+			entry = withoutMappingValue(entry, "position")
+		}
+
+		if before != "" && after != "" {
+			return nil, errors.Errorf("%s %q must not set both position.before and position.after", key, id)
+		}
+
+		index := indexOf(id)
+		if index >= 0 {
+			entry = mergeMappingNodes(result.Content[index], entry)
+
+			if before == "" && after == "" {
+				result.Content[index] = entry
+				continue
+			}
+
+			result.Content = append(result.Content[:index], result.Content[index+1:]...)
+		}
+
+		insertAt := len(result.Content)
+		switch {
+		case before != "":
+			insertAt = indexOf(before)
+			if insertAt < 0 {
+				return nil, errors.Errorf("%s %q is positioned before unknown %s %q", key, id, key, before)
+			}
+		case after != "":
+			insertAt = indexOf(after)
+			if insertAt < 0 {
+				return nil, errors.Errorf("%s %q is positioned after unknown %s %q", key, id, key, after)
+			}
+			insertAt++
+		}
+
+		result.Content = append(result.Content[:insertAt], append([]*yaml3.Node{entry}, result.Content[insertAt:]...)...)
+	}
+
+	return result, nil
+}
+
+// mergeMappingNodes deep merges the top mapping on top of the base mapping.
+// Anything other than two mappings is replaced by the top node.
+func mergeMappingNodes(base, top *yaml3.Node) *yaml3.Node {
+	if base == nil || base.Kind != yaml3.MappingNode || top.Kind != yaml3.MappingNode {
+		return top
+	}
+
+	result := &yaml3.Node{Kind: yaml3.MappingNode, Tag: base.Tag, Line: base.Line, Column: base.Column}
+	result.Content = append(result.Content, base.Content...)
+
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key, value := top.Content[i], top.Content[i+1]
+
+		replaced := false
+		for j := 0; j+1 < len(result.Content); j += 2 {
+			if result.Content[j].Value == key.Value {
+				result.Content[j+1] = mergeMappingNodes(result.Content[j+1], value)
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			result.Content = append(result.Content, key, value)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,45 @@
+package renderer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DirFS returns the filesystem of the config repository in dir. Like
+// os.DirFS, it rejects names that are not valid io/fs paths with fs.ErrInvalid,
+// so nothing outside of dir is read through it.
+func DirFS(dir string) fs.FS {
+	return os.DirFS(dir)
+}
+
+// LegacyDirFS returns the filesystem of the config repository in dir that
+// resolves names the way the paths of schemas on disk always were: `..`
+// segments may leave dir and absolute paths are relative to it. It is meant
+// for config repositories of the user running konfigure only, never for paths
+// from untrusted input.
+func LegacyDirFS(dir string) fs.FS {
+	return legacyDirFS(dir)
+}
+
+type legacyDirFS string
+
+func (d legacyDirFS) Open(name string) (fs.File, error) {
+	return os.Open(d.join(name))
+}
+
+func (d legacyDirFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(d.join(name))
+}
+
+func (d legacyDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(d.join(name))
+}
+
+func (d legacyDirFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(d.join(name))
+}
+
+func (d legacyDirFS) join(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
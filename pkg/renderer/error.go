@@ -0,0 +1,17 @@
+package renderer
+
+import (
+	"reflect"
+)
+
+type InvalidSchemaError struct {
+	message string
+}
+
+func (e *InvalidSchemaError) Error() string {
+	return "InvalidSchemaError: " + e.message
+}
+
+func (e *InvalidSchemaError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
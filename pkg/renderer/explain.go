@@ -0,0 +1,403 @@
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+const (
+	// ExplanationOperationValueMerge is a value file merged for the template
+	// of a layer that sets the path.
+	ExplanationOperationValueMerge = "ValueMerge"
+
+	// ExplanationOperationTemplateMerge is the rendered template of a layer
+	// merged on top of the previous layers.
+	ExplanationOperationTemplateMerge = "TemplateMerge"
+
+	// ExplanationOperationPatch is a patch of a layer applied after merging
+	// its rendered template.
+	ExplanationOperationPatch = "Patch"
+)
+
+// Explanation traces how the value at a path of the rendered ConfigMap or
+// Secret came to be.
+type Explanation struct {
+	Path string                        `json:"path"`
+	Type model.ValueMergeReferenceType `json:"type"`
+
+	// Found is false if the path is not set in the rendered result.
+	Found bool        `json:"found"`
+	Value interface{} `json:"value,omitempty"`
+
+	// Steps are all the steps that set the path, in order. Every step
+	// overrides the value of the previous ones.
+	Steps []ExplanationStep `json:"steps"`
+}
+
+type ExplanationStep struct {
+	Layer     string `json:"layer"`
+	File      string `json:"file,omitempty"`
+	Operation string `json:"operation"`
+
+	// Detail describes the operation, e.g. the JSON6902 patch operation or
+	// the layer whose template the value file was merged for.
+	Detail string `json:"detail,omitempty"`
+
+	// Value is the value at the path after the step, unless the step removed
+	// the path.
+	Value   interface{} `json:"value,omitempty"`
+	Removed bool        `json:"removed,omitempty"`
+}
+
+// Redact returns a copy of the explanation with its values replaced by
+// utils.RedactedValue, keeping the layers, files and operations of the steps.
+func (e *Explanation) Redact() *Explanation {
+	redacted := *e
+	if redacted.Found {
+		redacted.Value = utils.RedactedValue
+	}
+
+	redacted.Steps = make([]ExplanationStep, 0, len(e.Steps))
+	for _, step := range e.Steps {
+		if !step.Removed {
+			step.Value = utils.RedactedValue
+		}
+
+		redacted.Steps = append(redacted.Steps, step)
+	}
+
+	return &redacted
+}
+
+// LastWrite returns the template merge or patch step that last wrote the value
+// at the path, if any.
+func (e *Explanation) LastWrite() (ExplanationStep, bool) {
+	for i := len(e.Steps) - 1; i >= 0; i-- {
+		if e.Steps[i].Operation != ExplanationOperationValueMerge {
+			return e.Steps[i], true
+		}
+	}
+
+	return ExplanationStep{}, false
+}
+
+// ExplainPath folds the rendered templates of the given type the same way as
+// FoldAndPatchRenderedTemplates and records every step setting the path. The
+// path is either dot separated, e.g. `apps.ingress.hosts[0]`, or a JSON
+// pointer, e.g. `/apps/ingress/hosts/0`.
+func ExplainPath(schema *model.Schema, valueFiles *ValueFiles, templates *Templates, renderedTemplates *RenderedTemplates, patches *Patches, valueType model.ValueMergeReferenceType, path string) (*Explanation, error) {
+	segments, err := ParseValuePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	isConfigMap := strings.EqualFold(string(valueType), string(model.ValueMergeReferenceTypeConfigMap))
+	if !isConfigMap && !strings.EqualFold(string(valueType), string(model.ValueMergeReferenceTypeSecret)) {
+		return nil, errors.Errorf("unknown value reference type %s", valueType)
+	}
+
+	explanation := &Explanation{
+		Path:  path,
+		Type:  model.ValueMergeReferenceTypeSecret,
+		Steps: make([]ExplanationStep, 0),
+	}
+	if isConfigMap {
+		explanation.Type = model.ValueMergeReferenceTypeConfigMap
+	}
+
+	var document string
+
+	for _, layer := range schema.Layers {
+		if skippedLayer(layer, renderedTemplates, patches) {
+			continue
+		}
+
+		rendered, templatePath := renderedTemplates.Secrets[layer.Id], templates.SecretPaths[layer.Id]
+		patch, patchPath, patchType := patches.Secrets[layer.Id], patches.SecretPaths[layer.Id], layer.Patches.Secret.Type
+		if isConfigMap {
+			rendered, templatePath = renderedTemplates.ConfigMaps[layer.Id], templates.ConfigMapPaths[layer.Id]
+			patch, patchPath, patchType = patches.ConfigMaps[layer.Id], patches.ConfigMapPaths[layer.Id], layer.Patches.ConfigMap.Type
+		}
+
+		before, foundBefore, err := lookupValuePath(document, segments)
+		if err != nil {
+			return nil, err
+		}
+
+		_, foundInTemplate, err := lookupValuePath(rendered, segments)
+		if err != nil {
+			return nil, err
+		}
+
+		document, err = MergeYamlDocumentsWithOptions([]string{document, rendered}, layer.Merge.Lists)
+		if err != nil {
+			return nil, err
+		}
+
+		after, foundAfter, err := lookupValuePath(document, segments)
+		if err != nil {
+			return nil, err
+		}
+
+		if foundInTemplate || foundBefore != foundAfter || !reflect.DeepEqual(before, after) {
+			if foundInTemplate {
+				valueSteps, err := explainValueFiles(schema, layer, explanation.Type, valueFiles, segments)
+				if err != nil {
+					return nil, err
+				}
+
+				explanation.Steps = append(explanation.Steps, valueSteps...)
+			}
+
+			explanation.Steps = append(explanation.Steps, ExplanationStep{
+				Layer:     layer.Id,
+				File:      templatePath,
+				Operation: ExplanationOperationTemplateMerge,
+				Value:     after,
+				Removed:   !foundAfter,
+			})
+		}
+
+		var patchSteps []ExplanationStep
+		document, patchSteps, err = explainPatch(document, patch, patchType, segments)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, step := range patchSteps {
+			step.Layer = layer.Id
+			step.File = patchPath
+			explanation.Steps = append(explanation.Steps, step)
+		}
+	}
+
+	explanation.Value, explanation.Found, err = lookupValuePath(document, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return explanation, nil
+}
+
+// explainValueFiles returns a step for every value file merged for the
+// template of the layer that sets the path.
+func explainValueFiles(schema *model.Schema, layer model.Layer, valueType model.ValueMergeReferenceType, valueFiles *ValueFiles, segments []string) ([]ExplanationStep, error) {
+	references, err := ValueFileReferences(schema, layer, valueType)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []ExplanationStep
+
+	for _, reference := range references {
+		files := valueFiles.SecretFiles[reference.LayerId]
+		if reference.Type == model.ValueMergeReferenceTypeConfigMap {
+			files = valueFiles.ConfigMapFiles[reference.LayerId]
+		}
+
+		for _, file := range files {
+			value, found, err := lookupValuePath(file.Content, segments)
+			if err != nil {
+				return nil, err
+			}
+
+			if found {
+				steps = append(steps, ExplanationStep{
+					Layer:     reference.LayerId,
+					File:      file.Path,
+					Operation: ExplanationOperationValueMerge,
+					Detail:    fmt.Sprintf("%s values for the template of layer %s", reference.Type, layer.Id),
+					Value:     value,
+				})
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// explainPatch applies the patch to the document and returns a step for every
+// patch operation setting the path. JSON6902 patches are applied operation by
+// operation.
+func explainPatch(document, patch, patchType string, segments []string) (string, []ExplanationStep, error) {
+	isJSON6902 := patchType == "" || strings.EqualFold(patchType, model.PatchTypeJSON6902)
+
+	if strings.TrimSpace(patch) == "" || !isJSON6902 {
+		patched, err := ApplyPatchOfType(document, patch, patchType)
+		if err != nil {
+			return "", nil, err
+		}
+
+		step, changed, err := explainChange(document, patched, segments)
+		if err != nil || !changed {
+			return patched, nil, err
+		}
+
+		step.Detail = patchType
+
+		return patched, []ExplanationStep{step}, nil
+	}
+
+	jsonPatch, err := trimAndConvertYamlToJson(patch)
+	if err != nil {
+		return "", nil, err
+	}
+
+	decodedPatch, err := jsonpatch.DecodePatch([]byte(jsonPatch))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var steps []ExplanationStep
+
+	for _, operation := range decodedPatch {
+		encodedOperation, err := json.Marshal(operation)
+		if err != nil {
+			return "", nil, err
+		}
+
+		patched, err := ApplyPatch(document, fmt.Sprintf("[%s]", encodedOperation))
+		if err != nil {
+			return "", nil, err
+		}
+
+		step, changed, err := explainChange(document, patched, segments)
+		if err != nil {
+			return "", nil, err
+		}
+
+		operationPath, err := operation.Path()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if changed || pathsOverlap(segments, operationPath) {
+			step.Detail = fmt.Sprintf("%s %s", operation.Kind(), operationPath)
+			steps = append(steps, step)
+		}
+
+		document = patched
+	}
+
+	return document, steps, nil
+}
+
+func explainChange(before, after string, segments []string) (ExplanationStep, bool, error) {
+	beforeValue, foundBefore, err := lookupValuePath(before, segments)
+	if err != nil {
+		return ExplanationStep{}, false, err
+	}
+
+	afterValue, foundAfter, err := lookupValuePath(after, segments)
+	if err != nil {
+		return ExplanationStep{}, false, err
+	}
+
+	step := ExplanationStep{
+		Operation: ExplanationOperationPatch,
+		Value:     afterValue,
+		Removed:   !foundAfter,
+	}
+
+	return step, foundBefore != foundAfter || !reflect.DeepEqual(beforeValue, afterValue), nil
+}
+
+// pathsOverlap checks if the JSON pointer is the path or one of its parents
+// or children.
+func pathsOverlap(segments []string, pointer string) bool {
+	pointerSegments, err := ParseValuePath(pointer)
+	if err != nil {
+		return false
+	}
+
+	for i := 0; i < len(segments) && i < len(pointerSegments); i++ {
+		if segments[i] != pointerSegments[i] && pointerSegments[i] != "-" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseValuePath splits a dot separated path, e.g. `apps.hosts[0]`, or a JSON
+// pointer, e.g. `/apps/hosts/0`, into its segments.
+func ParseValuePath(path string) ([]string, error) {
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		}
+
+		return segments, nil
+	}
+
+	var segments []string
+
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		key, indexes, _ := strings.Cut(part, "[")
+		if key == "" && indexes == "" {
+			return nil, errors.Errorf("invalid path %q: empty key", path)
+		}
+
+		if key != "" {
+			segments = append(segments, key)
+		}
+
+		if indexes == "" {
+			continue
+		}
+
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, errors.Errorf("invalid path %q: invalid index %q", path, index)
+			}
+
+			segments = append(segments, index)
+		}
+	}
+
+	return segments, nil
+}
+
+// lookupValuePath returns the value at the path of the YAML document.
+func lookupValuePath(document string, segments []string) (interface{}, bool, error) {
+	jsonDocument, err := trimAndConvertYamlToJson(document)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, err := decodeJson([]byte(jsonDocument))
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var found bool
+			value, found = v[segment]
+			if !found {
+				return nil, false, nil
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false, nil
+			}
+			value = v[index]
+		default:
+			return nil, false, nil
+		}
+	}
+
+	return value, true, nil
+}
@@ -0,0 +1,44 @@
+package renderer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+func TestExplanation_Redact(t *testing.T) {
+	explanation := &Explanation{
+		Path:  "app.password",
+		Type:  model.ValueMergeReferenceTypeSecret,
+		Found: true,
+		Value: "hunter2",
+		Steps: []ExplanationStep{
+			{Layer: "base", File: "0-base/secret-values.yaml", Operation: ExplanationOperationValueMerge, Value: "hunter1"},
+			{Layer: "stages", File: "1-stages/patches.yaml", Operation: ExplanationOperationPatch, Detail: "remove /app/password", Removed: true},
+			{Layer: "apps", File: "2-apps/secret-template.yaml", Operation: ExplanationOperationTemplateMerge, Value: map[string]interface{}{"nested": "hunter2"}},
+		},
+	}
+
+	expected := &Explanation{
+		Path:  "app.password",
+		Type:  model.ValueMergeReferenceTypeSecret,
+		Found: true,
+		Value: utils.RedactedValue,
+		Steps: []ExplanationStep{
+			{Layer: "base", File: "0-base/secret-values.yaml", Operation: ExplanationOperationValueMerge, Value: utils.RedactedValue},
+			{Layer: "stages", File: "1-stages/patches.yaml", Operation: ExplanationOperationPatch, Detail: "remove /app/password", Removed: true},
+			{Layer: "apps", File: "2-apps/secret-template.yaml", Operation: ExplanationOperationTemplateMerge, Value: utils.RedactedValue},
+		},
+	}
+
+	redacted := explanation.Redact()
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, redacted)
+	}
+
+	if explanation.Value != "hunter2" || explanation.Steps[0].Value != "hunter1" {
+		t.Fatalf("Expected the explanation to be left as is, got %+v", explanation)
+	}
+}
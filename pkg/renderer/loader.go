@@ -1,29 +1,40 @@
 package renderer
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/giantswarm/konfigure/v2/pkg/utils"
 
-	sopsV3Decrypt "github.com/getsops/sops/v3/decrypt"
-
-	"gopkg.in/yaml.v3"
-
 	"github.com/giantswarm/konfigure/v2/pkg/model"
 )
 
+// LoadSchema loads the schema file and the schemas it extends, and returns
+// them as a single flattened schema.
 func LoadSchema(path string) (*model.Schema, error) {
-	content, err := os.ReadFile(filepath.Clean(path))
+	return loadSchema(osSchemaFiles{}, path)
+}
+
+// LoadSchemaFS works like LoadSchema, but loads the schema file at the slash
+// separated path and the schemas it extends from the filesystem.
+func LoadSchemaFS(fsys fs.FS, path string) (*model.Schema, error) {
+	return loadSchema(fsSchemaFiles{fsys: fsys}, path)
+}
+
+func loadSchema(files schemaFiles, path string) (*model.Schema, error) {
+	document, err := loadSchemaDocument(files, path, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var schema model.Schema
-	if err := yaml.Unmarshal(content, &schema); err != nil {
-		return nil, err
+	if err := document.Decode(&schema); err != nil {
+		return nil, &InvalidSchemaError{message: fmt.Sprintf("%s: %s", path, err)}
 	}
 
 	return &schema, nil
@@ -43,6 +54,15 @@ func LoadSchemaVariables(flagValues []string, variables []model.Variable) (Schem
 
 	for _, variable := range variables {
 		parsedValue, found := parsedFlagValues[variable.Name]
+
+		// Derived variables are resolved once all other variables are known.
+		if variable.Value != "" {
+			if found {
+				return schemaVariables, fmt.Errorf("variable %s is derived and cannot be set", variable.Name)
+			}
+			continue
+		}
+
 		if !found {
 			if variable.Required {
 				return schemaVariables, fmt.Errorf("variable %s is required", variable.Name)
@@ -51,81 +71,163 @@ func LoadSchemaVariables(flagValues []string, variables []model.Variable) (Schem
 			}
 		}
 
+		// Optional variables without a value are not validated.
+		if found || parsedValue != "" {
+			err := ValidateVariableValue(variable, parsedValue)
+			if err != nil {
+				return schemaVariables, err
+			}
+		}
+
 		schemaVariables[variable.Name] = parsedValue
 	}
 
+	err := resolveDerivedVariables(variables, schemaVariables)
+	if err != nil {
+		return schemaVariables, err
+	}
+
 	return schemaVariables, nil
 }
 
+// ValidateVariableValue checks the value against the allowed values and the
+// pattern of the variable. Patterns must match the whole value.
+func ValidateVariableValue(variable model.Variable, value string) error {
+	if len(variable.Enum) > 0 && !slices.Contains(variable.Enum, value) {
+		return fmt.Errorf("invalid value %q for variable %s: must be one of: %s", value, variable.Name, strings.Join(variable.Enum, ","))
+	}
+
+	if variable.Pattern != "" {
+		pattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", variable.Pattern))
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q for variable %s: %s", variable.Pattern, variable.Name, err)
+		}
+
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for variable %s: must match pattern %q", value, variable.Name, variable.Pattern)
+		}
+	}
+
+	return nil
+}
+
 func LoadValueFiles(dir string, schema *model.Schema, variables SchemaVariables) (*ValueFiles, error) {
+	return LoadValueFilesFS(LegacyDirFS(dir), schema, variables, nil)
+}
+
+// LoadValueFilesFS works like LoadValueFiles, but loads the value files from
+// the filesystem. The cache is optional.
+func LoadValueFilesFS(fsys fs.FS, schema *model.Schema, variables SchemaVariables, cache *Cache) (*ValueFiles, error) {
+	// Layers whose condition is not met are skipped.
+	schema, _, err := ResolveLayers(schema, variables)
+	if err != nil {
+		return nil, err
+	}
+
 	valueFiles := &ValueFiles{
-		ConfigMaps: make(map[string]string),
-		Secrets:    make(map[string]string),
+		ConfigMaps:     make(map[string]string),
+		Secrets:        make(map[string]string),
+		ConfigMapFiles: make(map[string][]SourceFile),
+		SecretFiles:    make(map[string][]SourceFile),
 	}
 
 	for _, layer := range schema.Layers {
 		// Config maps
-		if layer.Values.ConfigMap.Name == "" {
-			valueFiles.ConfigMaps[layer.Id] = ""
-		} else {
-			segments := []PathSegment{
-				{RenderValue(layer.Path.Directory, variables), layer.Path.Required},
-				{RenderValue(layer.Values.Path.Directory, variables), layer.Values.Path.Required},
-				{RenderValue(layer.Values.ConfigMap.Name, variables), layer.Values.ConfigMap.Required},
-			}
-
-			configMapValueFile, err := loadFileFromPathSegments(dir, segments)
+		configMapValueFile, configMapFiles, err := loadValueFile(fsys, layer, layer.Values.ConfigMap, variables, false, cache)
+		if err != nil {
+			return nil, err
+		}
 
-			if err != nil {
-				return nil, err
-			}
+		valueFiles.ConfigMaps[layer.Id] = configMapValueFile
+		valueFiles.ConfigMapFiles[layer.Id] = configMapFiles
 
-			valueFiles.ConfigMaps[layer.Id] = string(configMapValueFile)
+		// Secrets
+		secretValueFile, secretFiles, err := loadValueFile(fsys, layer, layer.Values.Secret, variables, true, cache)
+		if err != nil {
+			return nil, err
 		}
 
-		// Secrets
-		if layer.Values.Secret.Name == "" {
-			valueFiles.Secrets[layer.Id] = ""
-		} else {
-			segments := []PathSegment{
-				{RenderValue(layer.Path.Directory, variables), layer.Path.Required},
-				{RenderValue(layer.Values.Path.Directory, variables), layer.Values.Path.Required},
-				{RenderValue(layer.Values.Secret.Name, variables), layer.Values.Secret.Required},
-			}
+		valueFiles.Secrets[layer.Id] = secretValueFile
+		valueFiles.SecretFiles[layer.Id] = secretFiles
+	}
 
-			secretValueFile, err := loadFileFromPathSegments(dir, segments)
+	return valueFiles, nil
+}
+
+// loadValueFile loads all value files matching the names of the value and
+// merges them in order. Secret value files are decrypted one by one before
+// merging. The loaded files are returned as well.
+func loadValueFile(fsys fs.FS, layer model.Layer, value model.Value, variables SchemaVariables, decrypt bool, cache *Cache) (string, []SourceFile, error) {
+	patterns := value.Patterns()
+	if len(patterns) == 0 {
+		return "", nil, nil
+	}
+
+	segments := []PathSegment{
+		{RenderValue(layer.Path.Directory, variables), layer.Path.Required},
+		{RenderValue(layer.Values.Path.Directory, variables), layer.Values.Path.Required},
+	}
 
+	for i, pattern := range patterns {
+		patterns[i] = RenderValue(pattern, variables)
+	}
+
+	files, err := loadFilesFromPathSegments(fsys, segments, patterns, value.Required)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var documents []string
+	for i, file := range files {
+		if len(strings.TrimSpace(file.Content)) == 0 {
+			continue
+		}
+
+		if decrypt && utils.IsSOPSEncrypted([]byte(file.Content)) {
+			decrypted, err := cache.decrypt(file.Path, []byte(file.Content))
 			if err != nil {
-				return nil, err
+				return "", nil, err
 			}
 
-			var decryptedSecretValueFile []byte
-			if len(strings.TrimSpace(string(secretValueFile))) == 0 {
-				decryptedSecretValueFile = make([]byte, 0)
-			} else {
-				isSopsEncrypted := utils.IsSOPSEncrypted(secretValueFile)
+			files[i].Content = string(decrypted)
+		}
 
-				if isSopsEncrypted {
-					decryptedSecretValueFile, err = sopsV3Decrypt.Data(secretValueFile, "yaml")
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					decryptedSecretValueFile = secretValueFile
-				}
-			}
+		documents = append(documents, files[i].Content)
+	}
 
-			valueFiles.Secrets[layer.Id] = string(decryptedSecretValueFile)
+	var merged string
+	switch len(documents) {
+	case 0:
+	case 1:
+		merged = documents[0]
+	default:
+		merged, err = MergeYamlDocumentsWithOptions(documents, layer.Merge.Lists)
+		if err != nil {
+			return "", nil, err
 		}
 	}
 
-	return valueFiles, nil
+	return merged, files, nil
 }
 
 func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables) (*Templates, error) {
+	return LoadTemplatesFS(LegacyDirFS(dir), schema, variables, nil)
+}
+
+// LoadTemplatesFS works like LoadTemplates, but loads the templates from the
+// filesystem. The cache is optional.
+func LoadTemplatesFS(fsys fs.FS, schema *model.Schema, variables SchemaVariables, cache *Cache) (*Templates, error) {
+	// Layers whose condition is not met are skipped.
+	schema, _, err := ResolveLayers(schema, variables)
+	if err != nil {
+		return nil, err
+	}
+
 	loadedTemplates := &Templates{
-		ConfigMaps: make(map[string]string),
-		Secrets:    make(map[string]string),
+		ConfigMaps:     make(map[string]string),
+		Secrets:        make(map[string]string),
+		ConfigMapPaths: make(map[string]string),
+		SecretPaths:    make(map[string]string),
 	}
 
 	for _, layer := range schema.Layers {
@@ -138,11 +240,12 @@ func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables)
 				{RenderValue(layer.Templates.ConfigMap.Name, variables), layer.Templates.ConfigMap.Required},
 			}
 
-			configMapTemplate, err := loadFileFromPathSegments(dir, segments)
+			configMapTemplatePath, configMapTemplate, err := loadFileFromPathSegments(fsys, segments)
 			if err != nil {
 				return nil, err
 			}
 
+			loadedTemplates.ConfigMapPaths[layer.Id] = configMapTemplatePath
 			loadedTemplates.ConfigMaps[layer.Id] = string(configMapTemplate)
 		}
 
@@ -155,7 +258,7 @@ func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables)
 				{RenderValue(layer.Templates.Secret.Name, variables), layer.Templates.Secret.Required},
 			}
 
-			secretTemplate, err := loadFileFromPathSegments(dir, segments)
+			secretTemplatePath, secretTemplate, err := loadFileFromPathSegments(fsys, segments)
 			if err != nil {
 				return nil, err
 			}
@@ -167,7 +270,7 @@ func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables)
 				isSopsEncrypted := utils.IsSOPSEncrypted(secretTemplate)
 
 				if isSopsEncrypted {
-					decryptedSecretTemplate, err = sopsV3Decrypt.Data(secretTemplate, "yaml")
+					decryptedSecretTemplate, err = cache.decrypt(secretTemplatePath, secretTemplate)
 					if err != nil {
 						return nil, err
 					}
@@ -177,6 +280,7 @@ func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables)
 
 			}
 
+			loadedTemplates.SecretPaths[layer.Id] = secretTemplatePath
 			loadedTemplates.Secrets[layer.Id] = string(decryptedSecretTemplate)
 		}
 	}
@@ -185,9 +289,23 @@ func LoadTemplates(dir string, schema *model.Schema, variables SchemaVariables)
 }
 
 func LoadPatches(dir string, schema *model.Schema, variables SchemaVariables) (*Patches, error) {
+	return LoadPatchesFS(LegacyDirFS(dir), schema, variables)
+}
+
+// LoadPatchesFS works like LoadPatches, but loads the patches from the
+// filesystem.
+func LoadPatchesFS(fsys fs.FS, schema *model.Schema, variables SchemaVariables) (*Patches, error) {
+	// Layers whose condition is not met are skipped.
+	schema, _, err := ResolveLayers(schema, variables)
+	if err != nil {
+		return nil, err
+	}
+
 	loadedPatches := &Patches{
-		ConfigMaps: make(map[string]string),
-		Secrets:    make(map[string]string),
+		ConfigMaps:     make(map[string]string),
+		Secrets:        make(map[string]string),
+		ConfigMapPaths: make(map[string]string),
+		SecretPaths:    make(map[string]string),
 	}
 
 	for _, layer := range schema.Layers {
@@ -200,11 +318,12 @@ func LoadPatches(dir string, schema *model.Schema, variables SchemaVariables) (*
 				{RenderValue(layer.Patches.ConfigMap.Name, variables), layer.Patches.ConfigMap.Required},
 			}
 
-			configMapPatches, err := loadFileFromPathSegments(dir, segments)
+			configMapPatchesPath, configMapPatches, err := loadFileFromPathSegments(fsys, segments)
 			if err != nil {
 				return nil, err
 			}
 
+			loadedPatches.ConfigMapPaths[layer.Id] = configMapPatchesPath
 			loadedPatches.ConfigMaps[layer.Id] = string(configMapPatches)
 		}
 
@@ -217,11 +336,12 @@ func LoadPatches(dir string, schema *model.Schema, variables SchemaVariables) (*
 				{RenderValue(layer.Patches.Secret.Name, variables), layer.Patches.Secret.Required},
 			}
 
-			secretPatches, err := loadFileFromPathSegments(dir, segments)
+			secretPatchesPath, secretPatches, err := loadFileFromPathSegments(fsys, segments)
 			if err != nil {
 				return nil, err
 			}
 
+			loadedPatches.SecretPaths[layer.Id] = secretPatchesPath
 			loadedPatches.Secrets[layer.Id] = string(secretPatches)
 		}
 	}
@@ -229,25 +349,123 @@ func LoadPatches(dir string, schema *model.Schema, variables SchemaVariables) (*
 	return loadedPatches, nil
 }
 
-func loadFileFromPathSegments(dir string, segments []PathSegment) ([]byte, error) {
-	path := dir
+// loadFilesFromPathSegments loads the files matching the glob patterns in the
+// directory described by the segments. Matches of each pattern are returned in
+// lexical order, files matched by multiple patterns are only returned once.
+// Required patterns must match at least one file.
+func loadFilesFromPathSegments(fsys fs.FS, segments []PathSegment, patterns []string, required bool) ([]SourceFile, error) {
+	dir, found, err := resolvePathSegments(fsys, segments)
+	if err != nil || !found {
+		return nil, err
+	}
 
-	for _, segment := range segments {
-		path = strings.Join([]string{path, segment.Value}, string(os.PathSeparator))
+	var files []SourceFile
+	seen := make(map[string]bool)
 
-		_, err := os.Stat(path)
+	for _, pattern := range patterns {
+		patternPath := path.Join(dir, pattern)
+
+		matchedFiles, err := matchFiles(fsys, patternPath)
 		if err != nil {
-			if os.IsNotExist(err) {
-				if segment.Required {
-					return nil, fmt.Errorf("required path %s does not exist", path)
-				}
+			return nil, fmt.Errorf("invalid value file pattern %s: %s", pattern, err)
+		}
 
-				return make([]byte, 0), nil
-			} else {
+		if len(matchedFiles) == 0 && required {
+			return nil, fmt.Errorf("required path %s does not exist", patternPath)
+		}
+
+		for _, match := range matchedFiles {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+
+			content, err := fs.ReadFile(fsys, match)
+			if err != nil {
 				return nil, err
 			}
+
+			files = append(files, SourceFile{Path: match, Content: string(content)})
+		}
+	}
+
+	return files, nil
+}
+
+// matchFiles returns the files matching the glob pattern in lexical order. A
+// file named exactly like the pattern is the only match, so value files with
+// `*`, `?` or `[` in their name load like before names were glob patterns.
+func matchFiles(fsys fs.FS, pattern string) ([]string, error) {
+	info, err := fs.Stat(fsys, pattern)
+	if err == nil && !info.IsDir() {
+		return []string{pattern}, nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return nil, nil
+	}
+
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := fs.Stat(fsys, match)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+
+	return files, nil
+}
+
+// loadFileFromPathSegments loads the file described by the segments and
+// returns its path. The path is empty if an optional segment does not exist.
+func loadFileFromPathSegments(fsys fs.FS, segments []PathSegment) (string, []byte, error) {
+	filePath, found, err := resolvePathSegments(fsys, segments)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", make([]byte, 0), nil
+	}
+
+	content, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filePath, content, nil
+}
+
+// resolvePathSegments joins the segments to a path of the filesystem and checks
+// that every segment exists. Missing required segments are an error, missing
+// optional segments are reported as not found.
+func resolvePathSegments(fsys fs.FS, segments []PathSegment) (string, bool, error) {
+	result := "."
+
+	for _, segment := range segments {
+		result = path.Join(result, segment.Value)
+
+		_, err := fs.Stat(fsys, result)
+		if errors.Is(err, fs.ErrNotExist) {
+			if segment.Required {
+				return "", false, fmt.Errorf("required path %s does not exist", result)
+			}
+
+			return "", false, nil
+		} else if err != nil {
+			return "", false, err
 		}
 	}
 
-	return os.ReadFile(filepath.Clean(path))
+	return result, true, nil
 }
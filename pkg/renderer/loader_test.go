@@ -0,0 +1,752 @@
+package renderer
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+func TestLoadSchema_Strict(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		schema string
+
+		expectedErrorMessages []string
+	}{
+		{
+			name: "case 0 - valid schema",
+			schema: `variables:
+  - name: stage
+    required: true
+layers:
+  - id: base
+    path:
+      directory: base
+      required: true
+`,
+		},
+		{
+			name:   "case 1 - empty schema",
+			schema: "",
+		},
+		{
+			name: "case 2 - unknown fields are reported with their line",
+			schema: `variables:
+  - name: stage
+    requried: true
+layers:
+  - id: base
+    values:
+      configmap:
+        name: values.yaml
+`,
+			expectedErrorMessages: []string{
+				"schema.yaml: yaml: unmarshal errors:",
+				"line 3: field requried not found in type model.Variable",
+				"line 7: field configmap not found in type model.Values",
+			},
+		},
+		{
+			name: "case 3 - merge strategy options are not validated",
+			schema: `layers:
+  - id: base
+    templates:
+      configMap:
+        values:
+          merge:
+            strategy: CustomOrder
+            options:
+              order:
+                - layerId: base
+                  type: ConfigMap
+`,
+		},
+		{
+			name: "case 4 - type errors are reported with file",
+			schema: `layers:
+  - id: base
+    path: base
+`,
+			expectedErrorMessages: []string{
+				`schema.yaml: yaml: unmarshal errors:`,
+				`line 3: cannot unmarshal !!str`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schemaPath := filepath.Join(t.TempDir(), "schema.yaml")
+
+			err := os.WriteFile(schemaPath, []byte(tc.schema), 0600)
+			if err != nil {
+				t.Fatalf("failed to write schema: %s", err)
+			}
+
+			_, err = LoadSchema(schemaPath)
+
+			if len(tc.expectedErrorMessages) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error but got nil")
+			}
+
+			for _, expected := range tc.expectedErrorMessages {
+				expected = strings.ReplaceAll(expected, "schema.yaml", schemaPath)
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain %q, got %q", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSchemaVariables(t *testing.T) {
+	variables := []model.Variable{
+		{Name: "stage", Required: true, Enum: []string{"dev", "production"}},
+		{Name: "cluster", Required: false, Default: "mc-1", Pattern: "mc-[0-9]+"},
+		{Name: "app", Required: false, Pattern: "[a-z]+"},
+	}
+
+	testCases := []struct {
+		name string
+
+		flagValues []string
+
+		expected             SchemaVariables
+		expectedErrorMessage string
+	}{
+		{
+			name:       "case 0 - valid values and defaults",
+			flagValues: []string{"stage=dev"},
+			expected:   SchemaVariables{"stage": "dev", "cluster": "mc-1", "app": ""},
+		},
+		{
+			name:                 "case 1 - missing required variable",
+			flagValues:           []string{},
+			expectedErrorMessage: "variable stage is required",
+		},
+		{
+			name:                 "case 2 - value not in enum",
+			flagValues:           []string{"stage=prod"},
+			expectedErrorMessage: `invalid value "prod" for variable stage: must be one of: dev,production`,
+		},
+		{
+			name:                 "case 3 - value must match the whole pattern",
+			flagValues:           []string{"stage=dev", "cluster=mc-1-extra"},
+			expectedErrorMessage: `invalid value "mc-1-extra" for variable cluster: must match pattern "mc-[0-9]+"`,
+		},
+		{
+			name:                 "case 4 - explicitly set empty value is validated",
+			flagValues:           []string{"stage=dev", "app="},
+			expectedErrorMessage: `invalid value "" for variable app: must match pattern "[a-z]+"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := LoadSchemaVariables(tc.flagValues, variables)
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || err.Error() != tc.expectedErrorMessage {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadSchemaVariables_Derived(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		variables  []model.Variable
+		flagValues []string
+
+		expected             SchemaVariables
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0 - derived variables are evaluated in dependency order",
+			variables: []model.Variable{
+				{Name: "env", Value: `{{ if eq (variable "region") "eu" }}europe{{ else }}other{{ end }}`},
+				{Name: "region", Value: `{{ index . "management-cluster" | splitList "-" | first }}`},
+				{Name: "management-cluster", Required: true},
+			},
+			flagValues: []string{"management-cluster=eu-mc-1"},
+			expected:   SchemaVariables{"management-cluster": "eu-mc-1", "region": "eu", "env": "europe"},
+		},
+		{
+			name: "case 1 - derived variables cannot be set",
+			variables: []model.Variable{
+				{Name: "cluster", Required: true},
+				{Name: "region", Value: `{{ .cluster }}`},
+			},
+			flagValues:           []string{"cluster=a", "region=b"},
+			expectedErrorMessage: "variable region is derived and cannot be set",
+		},
+		{
+			name: "case 2 - cycles are detected",
+			variables: []model.Variable{
+				{Name: "a", Value: `{{ .b }}`},
+				{Name: "b", Value: `{{ .c }}`},
+				{Name: "c", Value: `{{ .a }}`},
+			},
+			expectedErrorMessage: "derived variables form a cycle: a -> b -> c -> a",
+		},
+		{
+			name: "case 3 - derived values are validated",
+			variables: []model.Variable{
+				{Name: "stage", Required: true},
+				{Name: "env", Value: `{{ .stage }}`, Enum: []string{"dev", "production"}},
+			},
+			flagValues:           []string{"stage=prod"},
+			expectedErrorMessage: `invalid value "prod" for variable env: must be one of: dev,production`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := LoadSchemaVariables(tc.flagValues, tc.variables)
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || err.Error() != tc.expectedErrorMessage {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadSchema_Extends(t *testing.T) {
+	base := `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+variables:
+  - name: stage
+    required: true
+layers:
+  - id: base
+    path:
+      directory: base
+      required: true
+    values:
+      configMap:
+        name: config.yaml
+  - id: stages
+    path:
+      directory: stages/<< stage >>
+includes:
+  - id: include
+    function:
+      name: include
+`
+
+	testCases := []struct {
+		name string
+
+		files map[string]string
+
+		expectedLayers       []model.Layer
+		expectedVariables    []model.Variable
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0 - layers are overridden and positioned",
+			files: map[string]string{
+				"parent/schema.yaml": base,
+				"schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: parent/schema.yaml
+variables:
+  - name: stage
+    default: dev
+  - name: cluster
+layers:
+  - id: base
+    values:
+      configMap:
+        required: true
+  - id: clusters
+    position:
+      after: base
+    path:
+      directory: clusters/<< cluster >>
+  - id: stages
+    position:
+      before: base
+`,
+			},
+			expectedVariables: []model.Variable{
+				{Name: "stage", Required: true, Default: "dev"},
+				{Name: "cluster"},
+			},
+			expectedLayers: []model.Layer{
+				{Id: "stages", Path: model.Path{Directory: "stages/<< stage >>"}},
+				{
+					Id:     "base",
+					Path:   model.Path{Directory: "base", Required: true},
+					Values: model.Values{ConfigMap: model.Value{Name: "config.yaml", Required: true}},
+				},
+				{Id: "clusters", Path: model.Path{Directory: "clusters/<< cluster >>"}},
+			},
+		},
+		{
+			name: "case 1 - extended schemas are resolved relative to the extending one",
+			files: map[string]string{
+				"parent/schema.yaml": base,
+				"parent/child.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: schema.yaml
+`,
+				"schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: parent/child.yaml
+layers:
+  - id: last
+`,
+			},
+			expectedVariables: []model.Variable{
+				{Name: "stage", Required: true},
+			},
+			expectedLayers: []model.Layer{
+				{
+					Id:     "base",
+					Path:   model.Path{Directory: "base", Required: true},
+					Values: model.Values{ConfigMap: model.Value{Name: "config.yaml"}},
+				},
+				{Id: "stages", Path: model.Path{Directory: "stages/<< stage >>"}},
+				{Id: "last"},
+			},
+		},
+		{
+			name: "case 2 - cycles are detected",
+			files: map[string]string{
+				"a.yaml": `apiVersion: konfigure.giantswarm.io/v1
+extends: schema.yaml
+`,
+				"schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+extends: a.yaml
+`,
+			},
+			expectedErrorMessage: "schemas extend each other in a cycle:",
+		},
+		{
+			name: "case 3 - unknown position reference",
+			files: map[string]string{
+				"parent/schema.yaml": base,
+				"schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+extends: parent/schema.yaml
+layers:
+  - id: clusters
+    position:
+      after: whatever
+`,
+			},
+			expectedErrorMessage: `id "clusters" is positioned after unknown id "whatever"`,
+		},
+		{
+			name: "case 4 - position without extends",
+			files: map[string]string{
+				"schema.yaml": `layers:
+  - id: base
+    position:
+      after: base
+`,
+			},
+			expectedErrorMessage: "layers[0].position is only supported in schemas extending another schema",
+		},
+		{
+			name: "case 5 - extending schemas must be versioned",
+			files: map[string]string{
+				"parent/schema.yaml": base,
+				"schema.yaml": `extends: parent/schema.yaml
+`,
+			},
+			expectedErrorMessage: `extending schemas must set apiVersion "konfigure.giantswarm.io/v1"`,
+		},
+		{
+			name: "case 6 - unknown fields in extended schemas are reported",
+			files: map[string]string{
+				"parent/schema.yaml": `layers:
+  - id: base
+    pth: base
+`,
+				"schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+extends: parent/schema.yaml
+`,
+			},
+			expectedErrorMessage: "parent/schema.yaml: yaml: unmarshal errors:\n  line 3: field pth not found in type model.Layer",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+
+				err := os.MkdirAll(filepath.Dir(path), 0700)
+				if err != nil {
+					t.Fatalf("failed to create directory: %s", err)
+				}
+
+				err = os.WriteFile(path, []byte(content), 0600)
+				if err != nil {
+					t.Fatalf("failed to write schema: %s", err)
+				}
+			}
+
+			schema, err := LoadSchema(filepath.Join(dir, "schema.yaml"))
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErrorMessage) {
+					t.Fatalf("expected error to contain %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(schema.Variables, tc.expectedVariables) {
+				t.Errorf("Expected variables %v, got %v", tc.expectedVariables, schema.Variables)
+			}
+
+			if !reflect.DeepEqual(schema.Layers, tc.expectedLayers) {
+				t.Errorf("Expected layers %+v, got %+v", tc.expectedLayers, schema.Layers)
+			}
+
+			if len(schema.Includes) != 1 || schema.Includes[0].Id != "include" {
+				t.Errorf("Expected includes of the extended schema, got %v", schema.Includes)
+			}
+		})
+	}
+}
+
+func TestLoadValueFiles(t *testing.T) {
+	files := map[string]string{
+		"base/values/10-apps.yaml":    "apps:\n  a: 1\nlevel: apps\n",
+		"base/values/20-infra.yaml":   "infra:\n  b: 2\nlevel: infra\n",
+		"base/values/override.yaml":   "level: override\n",
+		"base/values/secrets/a.yaml":  "password: a\n",
+		"base/values/secrets/b.yaml":  "token: b\n",
+		"base/literal/values[1].yaml": "level: literal\n",
+		"base/literal/values1.yaml":   "level: glob\n",
+	}
+
+	testCases := []struct {
+		name string
+
+		values model.Values
+
+		expectedConfigMap    string
+		expectedSecret       string
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0 - single file is loaded as is",
+			values: model.Values{
+				Path:      model.Path{Directory: "values"},
+				ConfigMap: model.Value{Name: "override.yaml"},
+			},
+			expectedConfigMap: "level: override\n",
+		},
+		{
+			name: "case 1 - glob matches are merged in lexical order",
+			values: model.Values{
+				Path:      model.Path{Directory: "values"},
+				ConfigMap: model.Value{Name: "[0-9]*.yaml"},
+				Secret:    model.Value{Name: "secrets/*.yaml"},
+			},
+			expectedConfigMap: "apps:\n    a: 1\ninfra:\n    b: 2\nlevel: infra\n",
+			expectedSecret:    "password: a\ntoken: b\n",
+		},
+		{
+			name: "case 2 - names are merged in order and files are loaded once",
+			values: model.Values{
+				Path: model.Path{Directory: "values"},
+				ConfigMap: model.Value{
+					Names: []string{"*.yaml", "10-apps.yaml", "<< stage >>.yaml"},
+				},
+			},
+			expectedConfigMap: "apps:\n    a: 1\ninfra:\n    b: 2\nlevel: override\n",
+		},
+		{
+			name: "case 3 - optional names without match are ignored",
+			values: model.Values{
+				Path:      model.Path{Directory: "values"},
+				ConfigMap: model.Value{Names: []string{"missing/*.yaml"}},
+			},
+			expectedConfigMap: "",
+		},
+		{
+			name: "case 4 - required names must match a file",
+			values: model.Values{
+				Path:      model.Path{Directory: "values"},
+				ConfigMap: model.Value{Names: []string{"*.yaml", "missing/*.yaml"}, Required: true},
+			},
+			expectedErrorMessage: "values/missing/*.yaml does not exist",
+		},
+		{
+			name: "case 5 - names of existing files are not glob patterns",
+			values: model.Values{
+				Path:      model.Path{Directory: "literal"},
+				ConfigMap: model.Value{Name: "values[1].yaml", Required: true},
+			},
+			expectedConfigMap: "level: literal\n",
+		},
+	}
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("failed to write value file: %s", err)
+		}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := &model.Schema{
+				Layers: []model.Layer{
+					{Id: "base", Path: model.Path{Directory: "base", Required: true}, Values: tc.values},
+				},
+			}
+
+			result, err := LoadValueFiles(dir, schema, SchemaVariables{"stage": "override"})
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErrorMessage) {
+					t.Fatalf("expected error to contain %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result.ConfigMaps["base"] != tc.expectedConfigMap {
+				t.Errorf("Expected config map %q, got %q", tc.expectedConfigMap, result.ConfigMaps["base"])
+			}
+
+			if result.Secrets["base"] != tc.expectedSecret {
+				t.Errorf("Expected secret %q, got %q", tc.expectedSecret, result.Secrets["base"])
+			}
+		})
+	}
+}
+
+func TestLoadValueFiles_DirPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"shared/values.yaml":         "shared: true\n",
+		"repo/base/values.yaml":      "base: true\n",
+		"shared/includes/label.tmpl": "shared-{{ . }}",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	values := model.Values{ConfigMap: model.Value{Name: "values.yaml"}}
+
+	// Directories leaving the config repository and absolute directories are
+	// joined to the config repository on disk.
+	schema := &model.Schema{
+		Layers: []model.Layer{
+			{Id: "shared", Path: model.Path{Directory: "../shared", Required: true}, Values: values},
+			{Id: "base", Path: model.Path{Directory: "/base", Required: true}, Values: values},
+			{Id: "missing", Path: model.Path{Directory: "../missing"}, Values: values},
+		},
+	}
+
+	result, err := LoadValueFiles(filepath.Join(dir, "repo"), schema, SchemaVariables{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{"shared": "shared: true\n", "base": "base: true\n", "missing": ""}
+	if !reflect.DeepEqual(result.ConfigMaps, expected) {
+		t.Fatalf("Expected config maps %v, got %v", expected, result.ConfigMaps)
+	}
+
+	functions := GenerateIncludeFunctions(filepath.Join(dir, "repo"), []model.Include{
+		{Function: model.IncludeFunction{Name: "label"}, Path: model.Path{Directory: "../shared/includes"}, Extension: ".tmpl"},
+	}, SchemaVariables{})
+
+	out, err := functions["label"].(func(string, interface{}) (string, error))("label", "app")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out != "shared-app" {
+		t.Fatalf("Expected include %q, got %q", "shared-app", out)
+	}
+
+	// The filesystem of the config repository does not let them leave it.
+	_, err = LoadValueFilesFS(DirFS(filepath.Join(dir, "repo")), schema, SchemaVariables{}, nil)
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected fs.ErrInvalid but got %v", err)
+	}
+}
+
+func TestSchemaFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"base/schema.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:
+  - id: base
+`,
+		"schemas/parent.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: ../base/schema.yaml
+`,
+		"schemas/app.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: parent.yaml
+`,
+		"schemas/broken.yaml": `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: missing.yaml
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("failed to write schema: %s", err)
+		}
+	}
+
+	paths, err := SchemaFiles(filepath.Join(dir, "schemas/app.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "schemas/app.yaml"),
+		filepath.Join(dir, "schemas/parent.yaml"),
+		filepath.Join(dir, "base/schema.yaml"),
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected paths %v, got %v", expected, paths)
+	}
+
+	// Missing extended schemas are returned with the error.
+	paths, err = SchemaFiles(filepath.Join(dir, "schemas/broken.yaml"))
+	if err == nil {
+		t.Fatalf("expected error for a schema extending a missing schema")
+	}
+
+	expected = []string{
+		filepath.Join(dir, "schemas/broken.yaml"),
+		filepath.Join(dir, "schemas/missing.yaml"),
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected paths %v, got %v", expected, paths)
+	}
+}
+
+func TestLoadSchemaFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schemas/base.yaml": {Data: []byte(`apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:
+  - id: base
+    path:
+      directory: base
+`)},
+		"schemas/app.yaml": {Data: []byte(`apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: base.yaml
+layers:
+  - id: app
+`)},
+		"escaping.yaml": {Data: []byte(`apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+extends: ../base.yaml
+`)},
+	}
+
+	schema, err := LoadSchemaFS(fsys, "schemas/app.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedLayers := []model.Layer{
+		{Id: "base", Path: model.Path{Directory: "base"}},
+		{Id: "app"},
+	}
+
+	if !reflect.DeepEqual(schema.Layers, expectedLayers) {
+		t.Fatalf("Expected layers %#v, got %#v", expectedLayers, schema.Layers)
+	}
+
+	// Extended schemas cannot be loaded from outside of the filesystem.
+	_, err = LoadSchemaFS(fsys, "escaping.yaml")
+	if err == nil {
+		t.Fatalf("expected error for a schema extending a schema outside of the filesystem")
+	}
+}
@@ -0,0 +1,237 @@
+package renderer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml2 "gopkg.in/yaml.v2"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+// MergeYamlDocumentsWithOptions merges the documents like MergeYamlDocuments,
+// but merges lists according to the given options instead of replacing them.
+func MergeYamlDocumentsWithOptions(valuesToMerge []string, options model.ListMergeOptions) (string, error) {
+	if !hasListMergeOptions(options) {
+		return MergeYamlDocuments(valuesToMerge)
+	}
+
+	err := ValidateListMergeOptions(options)
+	if err != nil {
+		return "", err
+	}
+
+	// A single document is merged anyway to get the same output format.
+	if len(valuesToMerge) < 2 {
+		return MergeYamlDocuments(valuesToMerge)
+	}
+
+	merged := valuesToMerge[0]
+
+	for _, valueToMerge := range valuesToMerge[1:] {
+		// Lists are always replaced when merging, so the lists of the top
+		// document are combined with the lists of the base document first.
+		prepared, err := prepareListsForMerge(merged, valueToMerge, options)
+		if err != nil {
+			return "", err
+		}
+
+		merged, err = MergeYamlDocuments([]string{merged, prepared})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return merged, nil
+}
+
+// ValidateListMergeOptions checks that all strategies of the options are known
+// and that merging by key has a key to merge by.
+func ValidateListMergeOptions(options model.ListMergeOptions) error {
+	err := ValidateListMergeStrategy(options.Strategy, options.Key)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range options.Paths {
+		if path.Path == "" {
+			return errors.New("list merge path must not be empty")
+		}
+
+		err = ValidateListMergeStrategy(path.Strategy, path.Key)
+		if err != nil {
+			return errors.Errorf("list merge path %s: %s", path.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateListMergeStrategy checks that the strategy is known and that merging
+// by key has a key to merge by.
+func ValidateListMergeStrategy(strategy, key string) error {
+	switch strings.ToLower(strategy) {
+	case "",
+		strings.ToLower(model.ListMergeStrategyReplace),
+		strings.ToLower(model.ListMergeStrategyAppend),
+		strings.ToLower(model.ListMergeStrategyPrepend):
+		return nil
+	case strings.ToLower(model.ListMergeStrategyMergeByKey):
+		if key == "" {
+			return errors.Errorf("list merge strategy %s requires a key", model.ListMergeStrategyMergeByKey)
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown list merge strategy %q, must be one of: %s", strategy, strings.Join(model.ListMergeStrategies, ","))
+	}
+}
+
+func hasListMergeOptions(options model.ListMergeOptions) bool {
+	return len(options.Paths) > 0 ||
+		(options.Strategy != "" && !strings.EqualFold(options.Strategy, model.ListMergeStrategyReplace))
+}
+
+// prepareListsForMerge returns the top document with all of its lists replaced
+// by the result of merging them with the lists at the same path in the base
+// document. Documents are parsed the same way as in MergeYamlDocuments, so
+// values like `yes` keep their meaning.
+func prepareListsForMerge(base, top string, options model.ListMergeOptions) (string, error) {
+	var baseValue, topValue interface{}
+
+	err := yaml2.Unmarshal([]byte(base), &baseValue)
+	if err != nil {
+		return "", err
+	}
+
+	err = yaml2.Unmarshal([]byte(top), &topValue)
+	if err != nil {
+		return "", err
+	}
+
+	baseMap, isBaseMap := baseValue.(map[interface{}]interface{})
+	topMap, isTopMap := topValue.(map[interface{}]interface{})
+	if !isBaseMap || !isTopMap {
+		return top, nil
+	}
+
+	output, err := yaml2.Marshal(mergeListsInMap(baseMap, topMap, nil, options))
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+func mergeListsInMap(base, top map[interface{}]interface{}, path []string, options model.ListMergeOptions) map[interface{}]interface{} {
+	result := make(map[interface{}]interface{}, len(top))
+
+	for key, topValue := range top {
+		result[key] = mergeLists(base[key], topValue, childPath(path, key), options)
+	}
+
+	return result
+}
+
+func mergeLists(base, top interface{}, path []string, options model.ListMergeOptions) interface{} {
+	switch topValue := top.(type) {
+	case map[interface{}]interface{}:
+		baseValue, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return top
+		}
+
+		return mergeListsInMap(baseValue, topValue, path, options)
+	case []interface{}:
+		baseValue, ok := base.([]interface{})
+		if !ok {
+			return top
+		}
+
+		strategy, key := listMergeStrategyForPath(path, options)
+
+		switch strings.ToLower(strategy) {
+		case strings.ToLower(model.ListMergeStrategyAppend):
+			return append(append([]interface{}{}, baseValue...), topValue...)
+		case strings.ToLower(model.ListMergeStrategyPrepend):
+			return append(append([]interface{}{}, topValue...), baseValue...)
+		case strings.ToLower(model.ListMergeStrategyMergeByKey):
+			return mergeListByKey(baseValue, topValue, key, path, options)
+		default:
+			return top
+		}
+	default:
+		return top
+	}
+}
+
+// mergeListByKey merges items of the top list into the items of the base list
+// with the same value for key. Other items of the top list are appended.
+func mergeListByKey(base, top []interface{}, key string, path []string, options model.ListMergeOptions) []interface{} {
+	result := append([]interface{}{}, base...)
+
+	for _, topItem := range top {
+		topMap, ok := topItem.(map[interface{}]interface{})
+		if !ok || topMap[key] == nil {
+			result = append(result, topItem)
+			continue
+		}
+
+		index := -1
+		for i, item := range result {
+			if itemMap, ok := item.(map[interface{}]interface{}); ok && reflect.DeepEqual(itemMap[key], topMap[key]) {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			result = append(result, topItem)
+			continue
+		}
+
+		result[index] = deepMergeMaps(result[index].(map[interface{}]interface{}), topMap, path, options)
+	}
+
+	return result
+}
+
+// deepMergeMaps merges the top map on top of the base map, merging nested
+// lists according to the options.
+func deepMergeMaps(base, top map[interface{}]interface{}, path []string, options model.ListMergeOptions) map[interface{}]interface{} {
+	result := make(map[interface{}]interface{}, len(base)+len(top))
+
+	for key, value := range base {
+		result[key] = value
+	}
+
+	for key, topValue := range top {
+		baseMap, isBaseMap := result[key].(map[interface{}]interface{})
+		topMap, isTopMap := topValue.(map[interface{}]interface{})
+
+		if isBaseMap && isTopMap {
+			result[key] = deepMergeMaps(baseMap, topMap, childPath(path, key), options)
+		} else {
+			result[key] = mergeLists(result[key], topValue, childPath(path, key), options)
+		}
+	}
+
+	return result
+}
+
+func listMergeStrategyForPath(path []string, options model.ListMergeOptions) (strategy, key string) {
+	joined := strings.Join(path, ".")
+
+	for _, listPath := range options.Paths {
+		if listPath.Path == joined {
+			return listPath.Strategy, listPath.Key
+		}
+	}
+
+	return options.Strategy, options.Key
+}
+
+func childPath(path []string, key interface{}) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), fmt.Sprint(key))
+}
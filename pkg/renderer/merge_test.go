@@ -0,0 +1,145 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+func TestMergeYamlDocumentsWithOptions(t *testing.T) {
+	base := `hosts:
+  - a.example.com
+apps:
+  - name: a
+    replicas: 1
+    env:
+      - A=1
+  - name: b
+    replicas: 1
+nested:
+  items:
+    - x
+`
+
+	top := `hosts:
+  - b.example.com
+apps:
+  - name: b
+    replicas: 2
+    env:
+      - B=1
+  - name: c
+nested:
+  items:
+    - z
+`
+
+	testCases := []struct {
+		name string
+
+		options model.ListMergeOptions
+
+		expected             string
+		expectedErrorMessage string
+	}{
+		{
+			name:    "case 0 - lists are replaced by default",
+			options: model.ListMergeOptions{},
+			expected: `apps:
+    - env:
+        - B=1
+      name: b
+      replicas: 2
+    - name: c
+hosts:
+    - b.example.com
+nested:
+    items:
+        - z
+`,
+		},
+		{
+			name:    "case 1 - append all lists",
+			options: model.ListMergeOptions{Strategy: model.ListMergeStrategyAppend},
+			expected: `apps:
+    - env:
+        - A=1
+      name: a
+      replicas: 1
+    - name: b
+      replicas: 1
+    - env:
+        - B=1
+      name: b
+      replicas: 2
+    - name: c
+hosts:
+    - a.example.com
+    - b.example.com
+nested:
+    items:
+        - x
+        - z
+`,
+		},
+		{
+			name: "case 2 - strategies per path",
+			options: model.ListMergeOptions{
+				Strategy: "prepend",
+				Paths: []model.ListMergePath{
+					{Path: "apps", Strategy: model.ListMergeStrategyMergeByKey, Key: "name"},
+					{Path: "apps.env", Strategy: model.ListMergeStrategyAppend},
+					{Path: "nested.items", Strategy: model.ListMergeStrategyReplace},
+				},
+			},
+			expected: `apps:
+    - env:
+        - A=1
+      name: a
+      replicas: 1
+    - env:
+        - B=1
+      name: b
+      replicas: 2
+    - name: c
+hosts:
+    - b.example.com
+    - a.example.com
+nested:
+    items:
+        - z
+`,
+		},
+		{
+			name:                 "case 3 - merge by key requires a key",
+			options:              model.ListMergeOptions{Strategy: model.ListMergeStrategyMergeByKey},
+			expectedErrorMessage: "list merge strategy MergeByKey requires a key",
+		},
+		{
+			name:                 "case 4 - unknown strategy",
+			options:              model.ListMergeOptions{Paths: []model.ListMergePath{{Path: "apps", Strategy: "Whatever"}}},
+			expectedErrorMessage: `list merge path apps: unknown list merge strategy "Whatever", must be one of: Replace,Append,Prepend,MergeByKey`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := MergeYamlDocumentsWithOptions([]string{base, top}, tc.options)
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || err.Error() != tc.expectedErrorMessage {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result != tc.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tc.expected, result)
+			}
+		})
+	}
+}
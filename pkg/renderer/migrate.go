@@ -0,0 +1,157 @@
+package renderer
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml3 "gopkg.in/yaml.v3"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+// legacySchemaAPIVersion is the implicit version of schemas written before
+// the format was versioned, these do not carry an `apiVersion` at all.
+const legacySchemaAPIVersion = ""
+
+type schemaMigration struct {
+	from    string
+	to      string
+	migrate func(schema *yaml3.Node) error
+}
+
+// schemaMigrations is the chain of migrations bringing any supported schema
+// version to the current one. Each migration operates on the YAML node tree,
+// so comments and ordering are preserved when a schema is rewritten.
+var schemaMigrations = []schemaMigration{
+	{
+		from:    legacySchemaAPIVersion,
+		to:      model.SchemaAPIVersionV1,
+		migrate: migrateLegacySchemaToV1,
+	},
+}
+
+// MigrateSchema rewrites the given schema to the current schema version. The
+// result is returned as is when the schema already is at the current version.
+func MigrateSchema(content []byte) ([]byte, error) {
+	var document yaml3.Node
+	err := yaml3.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	if document.Kind == 0 {
+		document = yaml3.Node{
+			Kind:    yaml3.DocumentNode,
+			Content: []*yaml3.Node{{Kind: yaml3.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	migrated, err := migrateSchemaNode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	if !migrated {
+		return content, nil
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := yaml3.NewEncoder(buf)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// migrateSchemaNode migrates the schema document node in place to the current
+// schema version and reports whether any migration was applied.
+func migrateSchemaNode(document *yaml3.Node) (bool, error) {
+	schema := document
+	if schema.Kind == yaml3.DocumentNode && len(schema.Content) > 0 {
+		schema = schema.Content[0]
+	}
+
+	if schema.Kind != yaml3.MappingNode {
+		return false, errors.Errorf("line %d: schema must be a mapping", schema.Line)
+	}
+
+	kind := scalarValue(schema, "kind")
+	if kind != "" && kind != model.SchemaKind {
+		return false, errors.Errorf("unsupported schema kind %q, expected %q", kind, model.SchemaKind)
+	}
+
+	migrated := false
+	for {
+		apiVersion := scalarValue(schema, "apiVersion")
+		if apiVersion == model.SchemaAPIVersionV1 {
+			return migrated, nil
+		}
+
+		found := false
+		for _, migration := range schemaMigrations {
+			if migration.from != apiVersion {
+				continue
+			}
+
+			err := migration.migrate(schema)
+			if err != nil {
+				return false, err
+			}
+
+			setScalarValue(schema, "apiVersion", migration.to, 0)
+			setScalarValue(schema, "kind", model.SchemaKind, 1)
+
+			found = true
+			migrated = true
+			break
+		}
+
+		if !found {
+			return false, errors.Errorf("unsupported schema apiVersion %q, expected %q", apiVersion, model.SchemaAPIVersionV1)
+		}
+	}
+}
+
+// migrateLegacySchemaToV1 pins the default value merge strategy for every
+// template that relies on it, so later changes of the default do not change
+// how the migrated schema renders.
+func migrateLegacySchemaToV1(schema *yaml3.Node) error {
+	layers := mappingValue(schema, "layers")
+	if layers == nil || layers.Kind != yaml3.SequenceNode {
+		return nil
+	}
+
+	for _, layer := range layers.Content {
+		templates := mappingValue(layer, "templates")
+
+		for _, templateType := range []string{"configMap", "secret"} {
+			template := mappingValue(templates, templateType)
+			if template == nil || template.Kind != yaml3.MappingNode {
+				continue
+			}
+
+			if strings.TrimSpace(scalarValue(template, "name")) == "" {
+				continue
+			}
+
+			values := ensureMapping(template, "values")
+			merge := ensureMapping(values, "merge")
+
+			if scalarValue(merge, "strategy") == "" {
+				setScalarValue(merge, "strategy", model.ValueFileMergeStrategySameTypeFromCurrentLayer, -1)
+			}
+		}
+	}
+
+	return nil
+}
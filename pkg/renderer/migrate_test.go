@@ -0,0 +1,109 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrateSchema(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		schema string
+
+		expected             string
+		expectedErrorMessage string
+	}{
+		{
+			name:   "case 0 - empty legacy schema",
+			schema: "",
+			expected: `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+`,
+		},
+		{
+			name: "case 1 - legacy schema gets default merge strategies pinned",
+			schema: `# Comments are kept.
+layers:
+  - id: base
+    templates:
+      configMap:
+        name: configmap.yaml
+      secret:
+        name: secret.yaml
+        values:
+          merge:
+            strategy: SecretsInLayerOrder
+  - id: empty
+    templates:
+      configMap:
+        name: ""
+`,
+			expected: `# Comments are kept.
+apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:
+  - id: base
+    templates:
+      configMap:
+        name: configmap.yaml
+        values:
+          merge:
+            strategy: SameTypeFromCurrentLayer
+      secret:
+        name: secret.yaml
+        values:
+          merge:
+            strategy: SecretsInLayerOrder
+  - id: empty
+    templates:
+      configMap:
+        name: ""
+`,
+		},
+		{
+			name: "case 2 - current schema is left untouched",
+			schema: `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:   []
+`,
+			expected: `apiVersion: konfigure.giantswarm.io/v1
+kind: KonfigurationSchema
+layers:   []
+`,
+		},
+		{
+			name: "case 3 - unsupported version",
+			schema: `apiVersion: konfigure.giantswarm.io/v9
+`,
+			expectedErrorMessage: `unsupported schema apiVersion "konfigure.giantswarm.io/v9"`,
+		},
+		{
+			name: "case 4 - unsupported kind",
+			schema: `kind: Konfiguration
+`,
+			expectedErrorMessage: `unsupported schema kind "Konfiguration"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := MigrateSchema([]byte(tc.schema))
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErrorMessage) {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(result) != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, string(result))
+			}
+		})
+	}
+}
@@ -7,22 +7,57 @@ type PathSegment struct {
 	Required bool
 }
 
+// SourceFile is a file loaded from the root directory, the path is relative to
+// the root directory.
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
 type ValueFiles struct {
 	ConfigMaps map[string]string
 	Secrets    map[string]string
+
+	// The files the value files of each layer were merged from, secrets are
+	// decrypted.
+	ConfigMapFiles map[string][]SourceFile
+	SecretFiles    map[string][]SourceFile
 }
 
 type Templates struct {
 	ConfigMaps map[string]string
 	Secrets    map[string]string
+
+	// The paths of the loaded templates of each layer.
+	ConfigMapPaths map[string]string
+	SecretPaths    map[string]string
 }
 
 type RenderedTemplates struct {
 	ConfigMaps map[string]string
 	Secrets    map[string]string
+
+	// The merged value files each template of each layer was rendered with.
+	ConfigMapValues map[string]string
+	SecretValues    map[string]string
+}
+
+// FoldStep holds the folded ConfigMap and Secret after merging the rendered
+// templates of a layer, and after applying the patches of the layer.
+type FoldStep struct {
+	Layer string
+
+	ConfigMapMerged  string
+	ConfigMapPatched string
+	SecretMerged     string
+	SecretPatched    string
 }
 
 type Patches struct {
 	ConfigMaps map[string]string
 	Secrets    map[string]string
+
+	// The paths of the loaded patches of each layer.
+	ConfigMapPaths map[string]string
+	SecretPaths    map[string]string
 }
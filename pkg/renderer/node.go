@@ -0,0 +1,101 @@
+package renderer
+
+import (
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// mappingValue returns the value node of the given key in a mapping node or
+// nil when the node is not a mapping or does not have the key.
+func mappingValue(node *yaml3.Node, key string) *yaml3.Node {
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func scalarValue(node *yaml3.Node, key string) string {
+	value := mappingValue(node, key)
+	if value == nil || value.Kind != yaml3.ScalarNode {
+		return ""
+	}
+
+	return value.Value
+}
+
+// setScalarValue sets the key of a mapping node to the given string value.
+// New keys are inserted at the given key position, negative positions append.
+func setScalarValue(node *yaml3.Node, key, value string, position int) {
+	if existing := mappingValue(node, key); existing != nil {
+		*existing = yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: value}
+		return
+	}
+
+	insertMappingValue(node, key, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: value}, position)
+}
+
+// ensureMapping returns the mapping value of the key, creating it when it
+// does not exist or is null.
+func ensureMapping(node *yaml3.Node, key string) *yaml3.Node {
+	existing := mappingValue(node, key)
+	if existing != nil && existing.Kind == yaml3.MappingNode {
+		return existing
+	}
+
+	mapping := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+	if existing != nil {
+		*existing = *mapping
+		return existing
+	}
+
+	insertMappingValue(node, key, mapping, -1)
+
+	return mapping
+}
+
+func insertMappingValue(node *yaml3.Node, key string, value *yaml3.Node, position int) {
+	keyNode := &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key}
+
+	index := position * 2
+	if position < 0 || index > len(node.Content) {
+		index = len(node.Content)
+	}
+
+	// Keep the comment at the top of the mapping where it was.
+	if index == 0 && len(node.Content) > 0 {
+		keyNode.HeadComment = node.Content[0].HeadComment
+		node.Content[0].HeadComment = ""
+	}
+
+	content := make([]*yaml3.Node, 0, len(node.Content)+2)
+	content = append(content, node.Content[:index]...)
+	content = append(content, keyNode, value)
+	content = append(content, node.Content[index:]...)
+
+	node.Content = content
+}
+
+// withoutMappingValue returns a shallow copy of the mapping node without the
+// given key.
+func withoutMappingValue(node *yaml3.Node, key string) *yaml3.Node {
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return node
+	}
+
+	result := *node
+	result.Content = make([]*yaml3.Node, 0, len(node.Content))
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			result.Content = append(result.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+
+	return &result
+}
@@ -1,6 +1,13 @@
 package renderer
 
-import "github.com/giantswarm/konfigure/v2/pkg/model"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
 
 func GetLayerOrder(schema *model.Schema) []string {
 	var result []string
@@ -11,3 +18,51 @@ func GetLayerOrder(schema *model.Schema) []string {
 
 	return result
 }
+
+// ResolveLayers evaluates the `when` condition of all layers against the
+// variables. It returns a copy of the schema with only the layers to apply,
+// and the layers that were skipped. The condition is a Go template that must
+// render to a boolean, an empty result counts as false.
+func ResolveLayers(schema *model.Schema, variables SchemaVariables) (*model.Schema, []model.Layer, error) {
+	resolved := *schema
+	resolved.Layers = make([]model.Layer, 0, len(schema.Layers))
+
+	var skipped []model.Layer
+
+	for _, layer := range schema.Layers {
+		if strings.TrimSpace(layer.When) == "" {
+			resolved.Layers = append(resolved.Layers, layer)
+			continue
+		}
+
+		result, err := evaluateExpression(layer.When, variables)
+		if err != nil {
+			return nil, nil, errors.Errorf("failed to evaluate condition of layer %s: %s", layer.Id, err)
+		}
+
+		apply := false
+		if result != "" {
+			apply, err = strconv.ParseBool(result)
+			if err != nil {
+				return nil, nil, errors.Errorf("condition of layer %s must render to a boolean, got %q", layer.Id, result)
+			}
+		}
+
+		if apply {
+			resolved.Layers = append(resolved.Layers, layer)
+		} else {
+			skipped = append(skipped, layer)
+		}
+	}
+
+	return &resolved, skipped, nil
+}
+
+// skippedLayer reports whether the condition of the layer was not met when
+// rendering the templates and loading the patches, then neither are known.
+func skippedLayer(layer model.Layer, renderedTemplates *RenderedTemplates, patches *Patches) bool {
+	_, rendered := renderedTemplates.ConfigMaps[layer.Id]
+	_, patched := patches.ConfigMaps[layer.Id]
+
+	return !rendered && !patched
+}
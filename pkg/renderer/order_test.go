@@ -2,6 +2,7 @@ package renderer
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/giantswarm/konfigure/v2/pkg/model"
@@ -49,3 +50,78 @@ func TestGetLayerOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveLayers(t *testing.T) {
+	schema := &model.Schema{
+		Layers: []model.Layer{
+			{
+				Id: "base",
+			},
+			{
+				Id:   "canary",
+				When: `{{ eq .stage "production" }}`,
+			},
+			{
+				Id:   "debug",
+				When: `{{ if eq .stage "dev" }}true{{ end }}`,
+			},
+		},
+	}
+
+	testCases := []struct {
+		name string
+
+		variables SchemaVariables
+
+		expected             []string
+		expectedSkipped      []string
+		expectedErrorMessage string
+	}{
+		{
+			name:            "case 0 - production",
+			variables:       SchemaVariables{"stage": "production"},
+			expected:        []string{"base", "canary"},
+			expectedSkipped: []string{"debug"},
+		},
+		{
+			name:            "case 1 - dev",
+			variables:       SchemaVariables{"stage": "dev"},
+			expected:        []string{"base", "debug"},
+			expectedSkipped: []string{"canary"},
+		},
+		{
+			name:                 "case 2 - missing variable",
+			variables:            SchemaVariables{},
+			expectedErrorMessage: "failed to evaluate condition of layer canary",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, skipped, err := ResolveLayers(schema, tc.variables)
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErrorMessage) {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result := GetLayerOrder(resolved); !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+
+			if result := GetLayerOrder(&model.Schema{Layers: skipped}); !reflect.DeepEqual(result, tc.expectedSkipped) {
+				t.Errorf("Expected skipped %v, got %v", tc.expectedSkipped, result)
+			}
+
+			if len(schema.Layers) != 3 {
+				t.Errorf("Expected the original schema to be left untouched")
+			}
+		})
+	}
+}
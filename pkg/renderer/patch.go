@@ -0,0 +1,314 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+const (
+	// strategicMergeDirective is the key of the directives in strategic merge
+	// patches, e.g. `$patch: delete`.
+	strategicMergeDirective = "$patch"
+
+	// strategicMergeKey identifies the items of lists in strategic merge
+	// patches.
+	strategicMergeKey = "name"
+
+	strategicMergeDirectiveMerge   = "merge"
+	strategicMergeDirectiveReplace = "replace"
+	strategicMergeDirectiveDelete  = "delete"
+)
+
+// ApplyPatchOfType applies the patch of the given type to the document. An
+// empty type defaults to a JSON6902 patch, see ApplyPatch.
+func ApplyPatchOfType(document, patch, patchType string) (string, error) {
+	switch strings.ToLower(patchType) {
+	case "", strings.ToLower(model.PatchTypeJSON6902):
+		return ApplyPatch(document, patch)
+	case strings.ToLower(model.PatchTypeMerge):
+		return applyMergePatch(document, patch, func(jsonDocument, jsonPatch []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(jsonDocument, jsonPatch)
+		})
+	case strings.ToLower(model.PatchTypeStrategicMerge):
+		return applyMergePatch(document, patch, strategicMergePatch)
+	default:
+		return "", errors.Errorf("unknown patch type %q, must be one of: %s", patchType, strings.Join(model.PatchTypes, ","))
+	}
+}
+
+func applyMergePatch(document, patch string, apply func(jsonDocument, jsonPatch []byte) ([]byte, error)) (string, error) {
+	// An empty merge patch would replace the document with null.
+	if strings.TrimSpace(patch) == "" {
+		return ApplyPatch(document, patch)
+	}
+
+	jsonPatch, err := trimAndConvertYamlToJson(patch)
+	if err != nil {
+		return "", err
+	}
+
+	jsonDocument, err := trimAndConvertYamlToJson(document)
+	if err != nil {
+		return "", err
+	}
+
+	if jsonDocument == "null" {
+		jsonDocument = "{}"
+	}
+
+	patchedDocument, err := apply([]byte(jsonDocument), []byte(jsonPatch))
+	if err != nil {
+		return "", err
+	}
+
+	return convertJsonToYaml(string(patchedDocument))
+}
+
+// strategicMergePatch applies a kustomize style strategic merge patch. Maps
+// are merged recursively and null values delete keys. Lists of maps are merged
+// by the `name` of their items, other lists are replaced. The `$patch`
+// directive can be set to `delete` to remove a map or list item, or to
+// `replace` to replace a map or list instead of merging it.
+func strategicMergePatch(jsonDocument, jsonPatch []byte) ([]byte, error) {
+	original, err := decodeJson(jsonDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := decodeJson(jsonPatch)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := strategicMergeValue(original, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(result)
+}
+
+// decodeJson decodes the document keeping numbers as is, so large integers
+// do not lose precision and are not rendered in scientific notation.
+func decodeJson(document []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var result interface{}
+	err := decoder.Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func strategicMergeValue(original, patch interface{}) (interface{}, error) {
+	switch patchValue := patch.(type) {
+	case map[string]interface{}:
+		directive, err := strategicMergeDirectiveOf(patchValue)
+		if err != nil {
+			return nil, err
+		}
+
+		originalMap, ok := original.(map[string]interface{})
+		if !ok || directive == strategicMergeDirectiveReplace {
+			return withoutStrategicMergeDirectives(patchValue)
+		}
+
+		return strategicMergeMap(originalMap, patchValue)
+	case []interface{}:
+		originalList, ok := original.([]interface{})
+		if !ok {
+			return withoutStrategicMergeDirectives(patchValue)
+		}
+
+		return strategicMergeList(originalList, patchValue)
+	default:
+		return patch, nil
+	}
+}
+
+func strategicMergeMap(original, patch map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(original)+len(patch))
+	for key, value := range original {
+		result[key] = value
+	}
+
+	for key, value := range patch {
+		if key == strategicMergeDirective {
+			continue
+		}
+
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+
+		if valueMap, ok := value.(map[string]interface{}); ok {
+			directive, err := strategicMergeDirectiveOf(valueMap)
+			if err != nil {
+				return nil, err
+			}
+
+			if directive == strategicMergeDirectiveDelete {
+				delete(result, key)
+				continue
+			}
+		}
+
+		merged, err := strategicMergeValue(result[key], value)
+		if err != nil {
+			return nil, err
+		}
+
+		result[key] = merged
+	}
+
+	return result, nil
+}
+
+func strategicMergeList(original, patch []interface{}) (interface{}, error) {
+	keyed := true
+	for _, item := range patch {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			keyed = false
+			break
+		}
+
+		directive, err := strategicMergeDirectiveOf(itemMap)
+		if err != nil {
+			return nil, err
+		}
+
+		// A `$patch: replace` item replaces the list with the other items.
+		if directive == strategicMergeDirectiveReplace {
+			return withoutStrategicMergeDirectives(patch)
+		}
+
+		if itemMap[strategicMergeKey] == nil {
+			keyed = false
+		}
+	}
+
+	if !keyed {
+		return withoutStrategicMergeDirectives(patch)
+	}
+
+	result := append([]interface{}{}, original...)
+
+	for _, item := range patch {
+		itemMap := item.(map[string]interface{})
+
+		index := -1
+		for i, originalItem := range result {
+			if originalMap, ok := originalItem.(map[string]interface{}); ok && reflect.DeepEqual(originalMap[strategicMergeKey], itemMap[strategicMergeKey]) {
+				index = i
+				break
+			}
+		}
+
+		directive, err := strategicMergeDirectiveOf(itemMap)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case directive == strategicMergeDirectiveDelete:
+			if index >= 0 {
+				result = append(result[:index], result[index+1:]...)
+			}
+		case index >= 0:
+			merged, err := strategicMergeValue(result[index], itemMap)
+			if err != nil {
+				return nil, err
+			}
+
+			result[index] = merged
+		default:
+			added, err := withoutStrategicMergeDirectives(itemMap)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, added)
+		}
+	}
+
+	return result, nil
+}
+
+func strategicMergeDirectiveOf(patch map[string]interface{}) (string, error) {
+	value, found := patch[strategicMergeDirective]
+	if !found {
+		return strategicMergeDirectiveMerge, nil
+	}
+
+	directive, _ := value.(string)
+
+	switch directive {
+	case strategicMergeDirectiveMerge, strategicMergeDirectiveReplace, strategicMergeDirectiveDelete:
+		return directive, nil
+	default:
+		return "", errors.Errorf(
+			"unknown strategic merge directive %s: %v, must be one of: %s,%s,%s",
+			strategicMergeDirective, value, strategicMergeDirectiveMerge, strategicMergeDirectiveReplace, strategicMergeDirectiveDelete,
+		)
+	}
+}
+
+// withoutStrategicMergeDirectives returns a copy of the value without any
+// directives, for values of the patch that are not merged with the document.
+func withoutStrategicMergeDirectives(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if key == strategicMergeDirective {
+				continue
+			}
+
+			cleaned, err := withoutStrategicMergeDirectives(item)
+			if err != nil {
+				return nil, err
+			}
+
+			result[key] = cleaned
+		}
+
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				directive, err := strategicMergeDirectiveOf(itemMap)
+				if err != nil {
+					return nil, err
+				}
+
+				if directive == strategicMergeDirectiveDelete || directive == strategicMergeDirectiveReplace && len(itemMap) == 1 {
+					continue
+				}
+			}
+
+			cleaned, err := withoutStrategicMergeDirectives(item)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, cleaned)
+		}
+
+		return result, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,166 @@
+package renderer
+
+import (
+	"testing"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+func TestApplyPatchOfType(t *testing.T) {
+	document := `replicas: 1
+image:
+  repository: app
+  tag: v1
+containers:
+  - name: app
+    env:
+      - name: A
+        value: "1"
+  - name: sidecar
+    image: sidecar
+ports:
+  - 80
+  - 443
+id: 12345678901234567
+`
+
+	testCases := []struct {
+		name string
+
+		patch     string
+		patchType string
+
+		expected             string
+		expectedErrorMessage string
+	}{
+		{
+			name:      "case 0 - JSON6902 patch by default",
+			patch:     "- op: replace\n  path: /replicas\n  value: 2\n",
+			patchType: "",
+			expected: `containers:
+- env:
+  - name: A
+    value: "1"
+  name: app
+- image: sidecar
+  name: sidecar
+id: 12345678901234567
+image:
+  repository: app
+  tag: v1
+ports:
+- 80
+- 443
+replicas: 2
+`,
+		},
+		{
+			name: "case 1 - merge patch replaces lists and deletes null values",
+			patch: `image:
+  tag: v2
+containers:
+  - name: other
+ports: null
+`,
+			patchType: model.PatchTypeMerge,
+			expected: `containers:
+- name: other
+id: 12345678901234567
+image:
+  repository: app
+  tag: v2
+replicas: 1
+`,
+		},
+		{
+			name: "case 2 - strategic merge patch merges lists by name",
+			patch: `image:
+  tag: v2
+containers:
+  - name: app
+    env:
+      - name: B
+        value: "2"
+  - name: sidecar
+    $patch: delete
+  - name: init
+    image: init
+ports:
+  - 8080
+`,
+			patchType: "strategicmerge",
+			expected: `containers:
+- env:
+  - name: A
+    value: "1"
+  - name: B
+    value: "2"
+  name: app
+- image: init
+  name: init
+id: 12345678901234567
+image:
+  repository: app
+  tag: v2
+ports:
+- 8080
+replicas: 1
+`,
+		},
+		{
+			name: "case 3 - strategic merge patch replaces and deletes",
+			patch: `image:
+  $patch: replace
+  repository: other
+containers:
+  - $patch: replace
+  - name: only
+replicas:
+  $patch: delete
+`,
+			patchType: model.PatchTypeStrategicMerge,
+			expected: `containers:
+- name: only
+id: 12345678901234567
+image:
+  repository: other
+ports:
+- 80
+- 443
+`,
+		},
+		{
+			name:                 "case 4 - unknown strategic merge directive",
+			patch:                "image:\n  $patch: whatever\n",
+			patchType:            model.PatchTypeStrategicMerge,
+			expectedErrorMessage: "unknown strategic merge directive $patch: whatever, must be one of: merge,replace,delete",
+		},
+		{
+			name:                 "case 5 - unknown patch type",
+			patch:                "replicas: 2\n",
+			patchType:            "Strategic",
+			expectedErrorMessage: `unknown patch type "Strategic", must be one of: JSON6902,Merge,StrategicMerge`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ApplyPatchOfType(document, tc.patch, tc.patchType)
+
+			if tc.expectedErrorMessage != "" {
+				if err == nil || err.Error() != tc.expectedErrorMessage {
+					t.Fatalf("expected error %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result != tc.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tc.expected, result)
+			}
+		})
+	}
+}
@@ -2,7 +2,8 @@ package renderer
 
 import (
 	"bytes"
-	"os"
+	"fmt"
+	"io/fs"
 	"path"
 	"strings"
 	"text/template"
@@ -19,13 +20,36 @@ import (
 	jsonpatch "github.com/evanphx/json-patch"
 )
 
-func RenderTemplates(dir string, schema *model.Schema, templates *Templates, valueFiles *ValueFiles) (*RenderedTemplates, error) {
+// VariableFunctionName is the name of the template function exposing the
+// schema variables to templates, e.g. `{{ variable "stage" }}`.
+const VariableFunctionName = "variable"
+
+func RenderTemplates(dir string, schema *model.Schema, templates *Templates, valueFiles *ValueFiles, variables SchemaVariables) (*RenderedTemplates, error) {
+	return RenderTemplatesFS(LegacyDirFS(dir), schema, templates, valueFiles, variables, nil)
+}
+
+// RenderTemplatesFS works like RenderTemplates, but loads included templates
+// from the filesystem. The cache is optional.
+func RenderTemplatesFS(fsys fs.FS, schema *model.Schema, templates *Templates, valueFiles *ValueFiles, variables SchemaVariables, cache *Cache) (*RenderedTemplates, error) {
+	// Layers whose condition is not met are skipped.
+	schema, _, err := ResolveLayers(schema, variables)
+	if err != nil {
+		return nil, err
+	}
+
 	renderedTemplates := &RenderedTemplates{
-		ConfigMaps: make(map[string]string),
-		Secrets:    make(map[string]string),
+		ConfigMaps:      make(map[string]string),
+		Secrets:         make(map[string]string),
+		ConfigMapValues: make(map[string]string),
+		SecretValues:    make(map[string]string),
+	}
+
+	err = validateIncludeFunctions(schema.Includes)
+	if err != nil {
+		return nil, err
 	}
 
-	extraIncludeFunctions := GenerateIncludeFunctions(dir, schema.Includes)
+	extraIncludeFunctions := GenerateIncludeFunctionsFS(fsys, schema.Includes, variables, cache)
 
 	for _, layer := range schema.Layers {
 		configMapMergedValueFiles, err := MergeValueFileReferences(schema, layer, model.ValueMergeReferenceTypeConfigMap, *valueFiles)
@@ -33,48 +57,84 @@ func RenderTemplates(dir string, schema *model.Schema, templates *Templates, val
 			return nil, err
 		}
 
-		renderedConfigMap, err := RenderTemplate(templates.ConfigMaps[layer.Id], configMapMergedValueFiles, extraIncludeFunctions)
+		renderedConfigMap, err := renderTemplate(templates.ConfigMapPaths[layer.Id], templates.ConfigMaps[layer.Id], configMapMergedValueFiles, extraIncludeFunctions, cache)
 		if err != nil {
 			return nil, err
 		}
 
 		renderedTemplates.ConfigMaps[layer.Id] = renderedConfigMap
+		renderedTemplates.ConfigMapValues[layer.Id] = configMapMergedValueFiles
 
 		secretMergedValueFiles, err := MergeValueFileReferences(schema, layer, model.ValueMergeReferenceTypeSecret, *valueFiles)
 		if err != nil {
 			return nil, err
 		}
 
-		renderedSecret, err := RenderTemplate(templates.Secrets[layer.Id], secretMergedValueFiles, extraIncludeFunctions)
+		renderedSecret, err := renderTemplate(templates.SecretPaths[layer.Id], templates.Secrets[layer.Id], secretMergedValueFiles, extraIncludeFunctions, cache)
 		if err != nil {
 			return nil, err
 		}
 
 		renderedTemplates.Secrets[layer.Id] = renderedSecret
+		renderedTemplates.SecretValues[layer.Id] = secretMergedValueFiles
 	}
 
 	return renderedTemplates, nil
 }
 
-func GenerateIncludeFunctions(dir string, includes []model.Include) template.FuncMap {
+func GenerateIncludeFunctions(dir string, includes []model.Include, variables SchemaVariables) template.FuncMap {
+	return GenerateIncludeFunctionsFS(LegacyDirFS(dir), includes, variables, nil)
+}
+
+// GenerateIncludeFunctionsFS works like GenerateIncludeFunctions, but the
+// include functions load the included templates from the filesystem. The cache
+// is optional.
+func GenerateIncludeFunctionsFS(fsys fs.FS, includes []model.Include, variables SchemaVariables, cache *Cache) template.FuncMap {
 	funcMap := sprig.TxtFuncMap()
+	funcMap[VariableFunctionName] = generateVariableFunction(variables)
 
 	for _, include := range includes {
-		funcMap[include.Function.Name] = generateIncludeFunction(dir, include)
+		funcMap[include.Function.Name] = generateIncludeFunction(fsys, include, variables, cache)
 	}
 
 	return funcMap
 }
 
-func generateIncludeFunction(dir string, include model.Include) func(templateName string, templateData interface{}) (string, error) {
+// validateIncludeFunctions rejects include functions that would replace the
+// built-in variable function.
+func validateIncludeFunctions(includes []model.Include) error {
+	for i, include := range includes {
+		if include.Function.Name == VariableFunctionName {
+			return &InvalidSchemaError{message: fmt.Sprintf("includes[%d].function.name %q is reserved for the function exposing schema variables", i, include.Function.Name)}
+		}
+	}
+
+	return nil
+}
+
+func generateVariableFunction(variables SchemaVariables) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		value, found := variables[name]
+		if !found {
+			return "", errors.Errorf("variable %q is not declared in the schema", name)
+		}
+
+		return value, nil
+	}
+}
+
+func generateIncludeFunction(fsys fs.FS, include model.Include, variables SchemaVariables, cache *Cache) func(templateName string, templateData interface{}) (string, error) {
 	return func(templateName string, templateData interface{}) (string, error) {
-		templateFilePath := path.Join(dir, include.Path.Directory, templateName+include.Extension)
-		contents, err := os.ReadFile(path.Clean(templateFilePath))
+		templateFilePath := path.Join(include.Path.Directory, templateName+include.Extension)
+		contents, err := fs.ReadFile(fsys, templateFilePath)
 		if err != nil {
 			return "", err
 		}
 
-		t, err := template.New(templateName).Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(string(contents))
+		funcMap := sprig.TxtFuncMap()
+		funcMap[VariableFunctionName] = generateVariableFunction(variables)
+
+		t, err := cache.parseTemplate(templateFilePath, templateName, string(contents), funcMap)
 		if err != nil {
 			return "", errors.Errorf("failed to parse template in file %q: %s", templateFilePath, err)
 		}
@@ -91,13 +151,19 @@ func generateIncludeFunction(dir string, include model.Include) func(templateNam
 
 // RenderTemplate This is what used to be generator.Generator.renderTemplate, but dynamic
 func RenderTemplate(text, data string, functions template.FuncMap) (string, error) {
+	return renderTemplate("", text, data, functions, nil)
+}
+
+// renderTemplate renders the template text of the file at path, parsing it
+// through the cache.
+func renderTemplate(path, text, data string, functions template.FuncMap, cache *Cache) (string, error) {
 	c := map[string]interface{}{}
 	err := yaml.Unmarshal([]byte(data), &c)
 	if err != nil {
 		return "", err
 	}
 
-	t, err := template.New("main").Funcs(functions).Option("missingkey=error").Parse(text)
+	t, err := cache.parseTemplate(path, "main", text, functions)
 	if err != nil {
 		return "", err
 	}
@@ -112,40 +178,67 @@ func RenderTemplate(text, data string, functions template.FuncMap) (string, erro
 }
 
 func FoldAndPatchRenderedTemplates(schema *model.Schema, renderedTemplates *RenderedTemplates, patches *Patches) (configmap string, secret string, err error) {
-	layerOrder := GetLayerOrder(schema)
+	configmap, secret, _, err = FoldAndPatchRenderedTemplatesWithSteps(schema, renderedTemplates, patches)
+	return configmap, secret, err
+}
+
+// FoldAndPatchRenderedTemplatesWithSteps works like FoldAndPatchRenderedTemplates
+// and returns the intermediate results of every layer as well.
+func FoldAndPatchRenderedTemplatesWithSteps(schema *model.Schema, renderedTemplates *RenderedTemplates, patches *Patches) (configmap string, secret string, steps []FoldStep, err error) {
+	for _, layer := range schema.Layers {
+		if skippedLayer(layer, renderedTemplates, patches) {
+			continue
+		}
+
+		step := FoldStep{Layer: layer.Id}
+
+		step.ConfigMapMerged, err = MergeYamlDocumentsWithOptions([]string{configmap, renderedTemplates.ConfigMaps[layer.Id]}, layer.Merge.Lists)
+		if err != nil {
+			return "", "", nil, err
+		}
 
-	for _, layer := range layerOrder {
-		configmap, err = MergeAndPatchRenderedTemplate(configmap, renderedTemplates.ConfigMaps[layer], patches.ConfigMaps[layer])
+		step.ConfigMapPatched, err = ApplyPatchOfType(step.ConfigMapMerged, patches.ConfigMaps[layer.Id], layer.Patches.ConfigMap.Type)
 		if err != nil {
-			return "", "", err
+			return "", "", nil, err
 		}
 
-		secret, err = MergeAndPatchRenderedTemplate(secret, renderedTemplates.Secrets[layer], patches.Secrets[layer])
+		step.SecretMerged, err = MergeYamlDocumentsWithOptions([]string{secret, renderedTemplates.Secrets[layer.Id]}, layer.Merge.Lists)
 		if err != nil {
-			return "", "", err
+			return "", "", nil, err
 		}
+
+		step.SecretPatched, err = ApplyPatchOfType(step.SecretMerged, patches.Secrets[layer.Id], layer.Patches.Secret.Type)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		configmap, secret = step.ConfigMapPatched, step.SecretPatched
+		steps = append(steps, step)
 	}
 
 	configmap, err = utils.SortYAMLKeys(configmap)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 
 	secret, err = utils.SortYAMLKeys(secret)
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 
-	return configmap, secret, nil
+	return configmap, secret, steps, nil
 }
 
-func MergeAndPatchRenderedTemplate(baseTemplate, topTemplate, patches string) (string, error) {
-	merged, err := MergeYamlDocuments([]string{baseTemplate, topTemplate})
+// MergeAndPatchRenderedTemplate merges the rendered template of a layer on top
+// of the already folded layers, merging lists according to the list merge
+// options of the layer, and applies the patches of the layer of the given type.
+func MergeAndPatchRenderedTemplate(baseTemplate, topTemplate, patches, patchType string, listMergeOptions model.ListMergeOptions) (string, error) {
+	merged, err := MergeYamlDocumentsWithOptions([]string{baseTemplate, topTemplate}, listMergeOptions)
 	if err != nil {
 		return "", err
 	}
 
-	patched, err := ApplyPatch(merged, patches)
+	patched, err := ApplyPatchOfType(merged, patches, patchType)
 	if err != nil {
 		return "", err
 	}
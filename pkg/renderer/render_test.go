@@ -0,0 +1,164 @@
+package renderer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+func TestRenderTemplatesFS_IncludeFunctions(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		includes []model.Include
+
+		expectedConfigMap    string
+		expectedErrorMessage string
+	}{
+		{
+			name: "case 0 - include functions are available next to the variable function",
+			includes: []model.Include{
+				{Id: "label", Function: model.IncludeFunction{Name: "label"}, Path: model.Path{Directory: "includes"}, Extension: ".tmpl"},
+			},
+			expectedConfigMap: "label: app-dev",
+		},
+		{
+			name: "case 1 - include functions must not replace the variable function",
+			includes: []model.Include{
+				{Id: "label", Function: model.IncludeFunction{Name: "label"}, Path: model.Path{Directory: "includes"}, Extension: ".tmpl"},
+				{Id: "variable", Function: model.IncludeFunction{Name: VariableFunctionName}, Path: model.Path{Directory: "includes"}, Extension: ".tmpl"},
+			},
+			expectedErrorMessage: `includes[1].function.name "variable" is reserved`,
+		},
+	}
+
+	fsys := fstest.MapFS{
+		"includes/label.tmpl": {Data: []byte(`app-{{ variable "stage" }}`)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := &model.Schema{
+				Layers:   []model.Layer{{Id: "base"}},
+				Includes: tc.includes,
+			}
+
+			templates := &Templates{
+				ConfigMaps: map[string]string{"base": `label: {{ label "label" . }}`},
+				Secrets:    map[string]string{"base": ""},
+			}
+
+			valueFiles := &ValueFiles{
+				ConfigMaps: map[string]string{"base": ""},
+				Secrets:    map[string]string{"base": ""},
+			}
+
+			result, err := RenderTemplatesFS(fsys, schema, templates, valueFiles, SchemaVariables{"stage": "dev"}, nil)
+
+			if tc.expectedErrorMessage != "" {
+				if !errors.Is(err, &InvalidSchemaError{}) || !strings.Contains(err.Error(), tc.expectedErrorMessage) {
+					t.Fatalf("expected InvalidSchemaError containing %q but got %v", tc.expectedErrorMessage, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if result.ConfigMaps["base"] != tc.expectedConfigMap {
+				t.Errorf("Expected config map %q, got %q", tc.expectedConfigMap, result.ConfigMaps["base"])
+			}
+		})
+	}
+}
+
+func TestFoldAndPatchRenderedTemplates_LayerConditions(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		variables SchemaVariables
+
+		expectedConfigMap string
+	}{
+		{
+			name:              "case 0 - layers whose condition is met are applied",
+			variables:         SchemaVariables{"stage": "production"},
+			expectedConfigMap: "replicas: 3\n",
+		},
+		{
+			name:              "case 1 - layers whose condition is not met are not loaded nor applied",
+			variables:         SchemaVariables{"stage": "dev"},
+			expectedConfigMap: "replicas: 1\n",
+		},
+	}
+
+	fsys := fstest.MapFS{
+		"base/configmap-values.yaml":     {Data: []byte("replicas: 1\n")},
+		"base/configmap-template.yaml":   {Data: []byte("replicas: {{ .replicas }}\n")},
+		"canary/configmap-values.yaml":   {Data: []byte("replicas: 3\n")},
+		"canary/configmap-template.yaml": {Data: []byte("replicas: {{ .replicas }}\n")},
+		"canary/configmap-patches.yaml":  {Data: []byte("[]\n")},
+	}
+
+	schema := &model.Schema{
+		Layers: []model.Layer{
+			{
+				Id:        "base",
+				Path:      model.Path{Directory: "base"},
+				Values:    model.Values{ConfigMap: model.Value{Name: "configmap-values.yaml"}},
+				Templates: model.Templates{ConfigMap: model.Template{Name: "configmap-template.yaml"}},
+			},
+			{
+				Id:        "canary",
+				When:      `{{ eq .stage "production" }}`,
+				Path:      model.Path{Directory: "canary"},
+				Values:    model.Values{ConfigMap: model.Value{Name: "configmap-values.yaml"}},
+				Templates: model.Templates{ConfigMap: model.Template{Name: "configmap-template.yaml"}},
+				Patches:   model.Patches{ConfigMap: model.PatchOptions{Name: "configmap-patches.yaml"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			valueFiles, err := LoadValueFilesFS(fsys, schema, tc.variables, nil)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			templates, err := LoadTemplatesFS(fsys, schema, tc.variables, nil)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			renderedTemplates, err := RenderTemplatesFS(fsys, schema, templates, valueFiles, tc.variables, nil)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			patches, err := LoadPatchesFS(fsys, schema, tc.variables)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			for _, loaded := range []map[string]string{valueFiles.ConfigMaps, templates.ConfigMaps, renderedTemplates.ConfigMaps, patches.ConfigMaps} {
+				if _, ok := loaded["canary"]; ok != (tc.variables["stage"] == "production") {
+					t.Fatalf("Expected the canary layer to be loaded only in production, got %v", loaded)
+				}
+			}
+
+			configMap, _, err := FoldAndPatchRenderedTemplates(schema, renderedTemplates, patches)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			if configMap != tc.expectedConfigMap {
+				t.Errorf("Expected config map %q, got %q", tc.expectedConfigMap, configMap)
+			}
+		})
+	}
+}
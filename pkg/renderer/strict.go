@@ -0,0 +1,29 @@
+package renderer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// decodeStrict decodes the YAML document into out, but unlike yaml.Unmarshal
+// it rejects fields that are not known to the target type. yaml.v3 reports all
+// unknown fields at once with the line they were found at, the file is
+// prefixed to its error.
+func decodeStrict(file string, content []byte, out interface{}) error {
+	decoder := yaml3.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(out)
+	if errors.Is(err, io.EOF) {
+		// Empty document, nothing to decode.
+		return nil
+	} else if err != nil {
+		return &InvalidSchemaError{message: fmt.Sprintf("%s: %s", file, err)}
+	}
+
+	return nil
+}
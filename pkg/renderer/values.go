@@ -9,6 +9,17 @@ import (
 )
 
 func MergeValueFileReferences(schema *model.Schema, layer model.Layer, valueType model.ValueMergeReferenceType, valueFiles ValueFiles) (string, error) {
+	references, err := ValueFileReferences(schema, layer, valueType)
+	if err != nil {
+		return "", err
+	}
+
+	return MergeYamlDocumentsWithOptions(valueFilesOf(references, valueFiles), layer.Merge.Lists)
+}
+
+// ValueFileReferences returns the value files merged for the template of the
+// given type of the layer according to its merge strategy, in merge order.
+func ValueFileReferences(schema *model.Schema, layer model.Layer, valueType model.ValueMergeReferenceType) ([]model.ValueMergeReference, error) {
 	valueTypeLower := strings.ToLower(string(valueType))
 	configMapTypeLower := strings.ToLower(string(model.ValueMergeReferenceTypeConfigMap))
 	secretTypeLower := strings.ToLower(string(model.ValueMergeReferenceTypeSecret))
@@ -20,105 +31,118 @@ func MergeValueFileReferences(schema *model.Schema, layer model.Layer, valueType
 	case secretTypeLower:
 		valueFileOptions = layer.Templates.Secret.Values
 	default:
-		return "", errors.Errorf("unknown value reference type %s", valueType)
+		return nil, errors.Errorf("unknown value reference type %s", valueType)
 	}
 
-	var valuesToMerge []string
-	var err error
+	sameType := model.ValueMergeReferenceTypeSecret
+	if valueTypeLower == configMapTypeLower {
+		sameType = model.ValueMergeReferenceTypeConfigMap
+	}
 
 	switch strings.ToLower(valueFileOptions.Merge.Strategy) {
 	case strings.ToLower(model.ValueFileMergeStrategyCustomOrder):
-		valuesToMerge, err = CustomOrderFilter(valueFileOptions.Merge.Options, valueFiles)
+		return customOrderReferences(valueFileOptions.Merge.Options)
 	case strings.ToLower(model.ValueFileMergeStrategySameTypeInLayerOrder):
-		if valueTypeLower == configMapTypeLower {
-			valuesToMerge, err = ConfigMapsInLayerOrderFilter(schema, valueFiles)
-		} else {
-			valuesToMerge, err = SecretsInLayerOrderFilter(schema, valueFiles)
-		}
+		return layerOrderReferences(schema, sameType), nil
 	case strings.ToLower(model.ValueFileMergeStrategyConfigMapsInLayerOrder):
-		valuesToMerge, err = ConfigMapsInLayerOrderFilter(schema, valueFiles)
+		return layerOrderReferences(schema, model.ValueMergeReferenceTypeConfigMap), nil
 	case strings.ToLower(model.ValueFileMergeStrategySecretsInLayerOrder):
-		valuesToMerge, err = SecretsInLayerOrderFilter(schema, valueFiles)
+		return layerOrderReferences(schema, model.ValueMergeReferenceTypeSecret), nil
 	case strings.ToLower(model.ValueFileMergeStrategyConfigMapsAndSecretsInLayerOrder):
-		valuesToMerge, err = ConfigMapsAndSecretsInLayerOrderFilter(schema, valueFiles)
+		return append(
+			layerOrderReferences(schema, model.ValueMergeReferenceTypeConfigMap),
+			layerOrderReferences(schema, model.ValueMergeReferenceTypeSecret)...,
+		), nil
 	case "":
 		fallthrough
 	case strings.ToLower(model.ValueFileMergeStrategySameTypeFromCurrentLayer):
-		if valueTypeLower == configMapTypeLower {
-			valuesToMerge = append(valuesToMerge, valueFiles.ConfigMaps[layer.Id])
-		} else {
-			valuesToMerge = append(valuesToMerge, valueFiles.Secrets[layer.Id])
-		}
+		return []model.ValueMergeReference{{LayerId: layer.Id, Type: sameType}}, nil
 	case strings.ToLower(model.ValueFileMergeStrategyConfigMapAndSecretFromCurrentLayer):
-		valuesToMerge = append(valuesToMerge, valueFiles.ConfigMaps[layer.Id], valueFiles.Secrets[layer.Id])
+		return []model.ValueMergeReference{
+			{LayerId: layer.Id, Type: model.ValueMergeReferenceTypeConfigMap},
+			{LayerId: layer.Id, Type: model.ValueMergeReferenceTypeSecret},
+		}, nil
 	default:
-		return "", errors.Errorf("unknown value merge strategy %q", valueFileOptions.Merge.Strategy)
+		return nil, errors.Errorf("unknown value merge strategy %q", valueFileOptions.Merge.Strategy)
 	}
+}
 
+func CustomOrderFilter(rawOptions model.RawMessage, valueFiles ValueFiles) ([]string, error) {
+	references, err := customOrderReferences(rawOptions)
 	if err != nil {
-		return "", err
+		return []string{}, err
 	}
 
-	return MergeYamlDocuments(valuesToMerge)
+	return valueFilesOf(references, valueFiles), nil
 }
 
-func CustomOrderFilter(rawOptions model.RawMessage, valueFiles ValueFiles) ([]string, error) {
+func ConfigMapsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
+	return valueFilesOf(layerOrderReferences(schema, model.ValueMergeReferenceTypeConfigMap), valueFiles), nil
+}
+
+func SecretsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
+	return valueFilesOf(layerOrderReferences(schema, model.ValueMergeReferenceTypeSecret), valueFiles), nil
+}
+
+func ConfigMapsAndSecretsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
+	configMapsInOrder, err := ConfigMapsInLayerOrderFilter(schema, valueFiles)
+	if err != nil {
+		return []string{}, nil
+	}
+
+	secretsInOrder, err := SecretsInLayerOrderFilter(schema, valueFiles)
+	if err != nil {
+		return []string{}, nil
+	}
+
+	return append(configMapsInOrder, secretsInOrder...), nil
+}
+
+func customOrderReferences(rawOptions model.RawMessage) ([]model.ValueMergeReference, error) {
 	options := model.CustomOrderValueMergeStrategyOptions{}
 	err := rawOptions.Unmarshal(&options)
 	if err != nil {
 		return nil, err
 	}
 
-	valuesToMerge := make([]string, 0)
+	references := make([]model.ValueMergeReference, 0)
 
 	for _, valueMergeReference := range options.Order {
 		switch strings.ToLower(string(valueMergeReference.Type)) {
 		case strings.ToLower(string(model.ValueMergeReferenceTypeConfigMap)):
-			valuesToMerge = append(valuesToMerge, valueFiles.ConfigMaps[valueMergeReference.LayerId])
+			references = append(references, model.ValueMergeReference{LayerId: valueMergeReference.LayerId, Type: model.ValueMergeReferenceTypeConfigMap})
 		case strings.ToLower(string(model.ValueMergeReferenceTypeSecret)):
-			valuesToMerge = append(valuesToMerge, valueFiles.Secrets[valueMergeReference.LayerId])
+			references = append(references, model.ValueMergeReference{LayerId: valueMergeReference.LayerId, Type: model.ValueMergeReferenceTypeSecret})
 		default:
-			return []string{}, errors.Errorf("unknown value merge reference type %s", valueMergeReference.Type)
+			return nil, errors.Errorf("unknown value merge reference type %s", valueMergeReference.Type)
 		}
 	}
 
-	return valuesToMerge, nil
+	return references, nil
 }
 
-func ConfigMapsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
-	order := GetLayerOrder(schema)
-
-	valuesToMerge := make([]string, 0)
+func layerOrderReferences(schema *model.Schema, valueType model.ValueMergeReferenceType) []model.ValueMergeReference {
+	references := make([]model.ValueMergeReference, 0)
 
-	for _, layer := range order {
-		valuesToMerge = append(valuesToMerge, valueFiles.ConfigMaps[layer])
+	for _, layer := range GetLayerOrder(schema) {
+		references = append(references, model.ValueMergeReference{LayerId: layer, Type: valueType})
 	}
 
-	return valuesToMerge, nil
+	return references
 }
 
-func SecretsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
-	order := GetLayerOrder(schema)
-
-	valuesToMerge := make([]string, 0)
+// valueFilesOf returns the value files of the references in order. The types
+// of the references must be normalized.
+func valueFilesOf(references []model.ValueMergeReference, valueFiles ValueFiles) []string {
+	valuesToMerge := make([]string, 0, len(references))
 
-	for _, layer := range order {
-		valuesToMerge = append(valuesToMerge, valueFiles.Secrets[layer])
-	}
-
-	return valuesToMerge, nil
-}
-
-func ConfigMapsAndSecretsInLayerOrderFilter(schema *model.Schema, valueFiles ValueFiles) ([]string, error) {
-	configMapsInOrder, err := ConfigMapsInLayerOrderFilter(schema, valueFiles)
-	if err != nil {
-		return []string{}, nil
-	}
-
-	secretsInOrder, err := SecretsInLayerOrderFilter(schema, valueFiles)
-	if err != nil {
-		return []string{}, nil
+	for _, reference := range references {
+		if reference.Type == model.ValueMergeReferenceTypeConfigMap {
+			valuesToMerge = append(valuesToMerge, valueFiles.ConfigMaps[reference.LayerId])
+		} else {
+			valuesToMerge = append(valuesToMerge, valueFiles.Secrets[reference.LayerId])
+		}
 	}
 
-	return append(configMapsInOrder, secretsInOrder...), nil
+	return valuesToMerge
 }
@@ -0,0 +1,194 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+)
+
+// ExpressionVariables returns the names of the variables referenced by the
+// expression, either as fields, e.g. `{{ .stage }}`, or by name, e.g.
+// `{{ index . "management-cluster" }}` or `{{ variable "stage" }}`.
+func ExpressionVariables(expression string) ([]string, error) {
+	t, err := parseExpression(expression, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, command := range n.Cmds {
+				walk(command)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 0 {
+				if identifier, ok := n.Args[0].(*parse.IdentifierNode); ok {
+					switch {
+					case identifier.Ident == VariableFunctionName && len(n.Args) > 1:
+						if name, ok := n.Args[1].(*parse.StringNode); ok {
+							add(name.Text)
+						}
+					case identifier.Ident == "index" && len(n.Args) > 2:
+						if _, ok := n.Args[1].(*parse.DotNode); ok {
+							if name, ok := n.Args[2].(*parse.StringNode); ok {
+								add(name.Text)
+							}
+						}
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			add(n.Ident[0])
+		case *parse.ChainNode:
+			walk(n.Node)
+		}
+	}
+
+	walk(t.Root)
+
+	return names, nil
+}
+
+// evaluateExpression renders the expression as a Go template against the
+// given variables and returns the result without surrounding whitespace.
+func evaluateExpression(expression string, variables SchemaVariables) (string, error) {
+	t, err := parseExpression(expression, variables)
+	if err != nil {
+		return "", err
+	}
+
+	out := bytes.NewBuffer([]byte{})
+	err = t.Execute(out, map[string]string(variables))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+func parseExpression(expression string, variables SchemaVariables) (*template.Template, error) {
+	funcMap := sprig.TxtFuncMap()
+	funcMap[VariableFunctionName] = generateVariableFunction(variables)
+
+	return template.New("expression").Funcs(funcMap).Option("missingkey=error").Parse(expression)
+}
+
+// resolveDerivedVariables evaluates the value of all derived variables in
+// dependency order and stores them in schemaVariables. Derived variables
+// referencing each other in a cycle are rejected.
+func resolveDerivedVariables(variables []model.Variable, schemaVariables SchemaVariables) error {
+	derived := make(map[string]model.Variable)
+	for _, variable := range variables {
+		if variable.Value != "" {
+			derived[variable.Name] = variable
+		}
+	}
+
+	const (
+		visiting = 1
+		resolved = 2
+	)
+
+	state := make(map[string]int)
+
+	var resolve func(name string, path []string) error
+	resolve = func(name string, path []string) error {
+		switch state[name] {
+		case resolved:
+			return nil
+		case visiting:
+			return fmt.Errorf("derived variables form a cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+
+		variable := derived[name]
+
+		dependencies, err := ExpressionVariables(variable.Value)
+		if err != nil {
+			return fmt.Errorf("invalid value expression for variable %s: %s", name, err)
+		}
+
+		for _, dependency := range dependencies {
+			if _, isDerived := derived[dependency]; isDerived {
+				err = resolve(dependency, append(path, name))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		value, err := evaluateExpression(variable.Value, schemaVariables)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate value of variable %s: %s", name, err)
+		}
+
+		err = ValidateVariableValue(variable, value)
+		if err != nil {
+			return err
+		}
+
+		schemaVariables[name] = value
+		state[name] = resolved
+
+		return nil
+	}
+
+	for _, variable := range variables {
+		if variable.Value == "" {
+			continue
+		}
+
+		err := resolve(variable.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"reflect"
+)
+
+type InvalidConfigError struct {
+	message string
+}
+
+func (e *InvalidConfigError) Error() string {
+	return "InvalidConfigError: " + e.message
+}
+
+func (e *InvalidConfigError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
+
+type InvalidRequestError struct {
+	message string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return "InvalidRequestError: " + e.message
+}
+
+func (e *InvalidRequestError) Is(target error) bool {
+	return reflect.TypeOf(target) == reflect.TypeOf(e)
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/service"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv"
+	"github.com/giantswarm/konfigure/v2/pkg/sopsenv/key"
+)
+
+const (
+	// maxRequestBytes limits the size of render requests, which hold little
+	// more than variables and SOPS keys.
+	maxRequestBytes = 1 << 20
+
+	defaultNamespace = "default"
+
+	keysDirPrefix = "konfigure-serve-sops-"
+)
+
+type Config struct {
+	Logger logr.Logger
+
+	// Root directory of the config repository. Schema paths of requests are
+	// relative to it.
+	Dir string
+}
+
+// Server renders the config repository over HTTP. It serves:
+//
+//	POST /render   renders a RenderRequest into a RenderResponse
+//	GET  /healthz  reports the server is up
+//
+// Requests without SOPS keys decrypt with the keys SOPS finds in the
+// environment of the process and share a cache of decrypted files. SOPS reads
+// its keys from process wide environment variables, so requests bringing their
+// own keys are rendered one at a time, with none of the other requests running,
+// and do not use the cache.
+type Server struct {
+	logger logr.Logger
+	dir    string
+
+	cachedService *service.DynamicService
+	keysService   *service.DynamicService
+
+	// sopsMu is held for writing while the SOPS environment is pointed to the
+	// keys of a request, and for reading by all other renders.
+	sopsMu sync.RWMutex
+
+	mux *http.ServeMux
+}
+
+// RenderRequest holds the inputs of a render, they match the flags of the
+// render command.
+type RenderRequest struct {
+	// Path to the schema file, relative to the root of the config repository.
+	Schema string `json:"schema"`
+
+	// Variables for the schema by name.
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// The name of the generated ConfigMap and Secret.
+	Name string `json:"name"`
+
+	// The namespace of the generated ConfigMap and Secret, defaults to
+	// `default`.
+	Namespace string `json:"namespace,omitempty"`
+
+	ExtraAnnotations map[string]string `json:"extraAnnotations,omitempty"`
+	ExtraLabels      map[string]string `json:"extraLabels,omitempty"`
+
+	ConfigMapDataKey string `json:"configMapDataKey,omitempty"`
+	SecretDataKey    string `json:"secretDataKey,omitempty"`
+
+	// SOPS private keys used to decrypt this request only, named like the
+	// data of the Kubernetes Secrets holding SOPS keys: `<name>.agekey` for
+	// AGE keys and `<name>.asc` for PGP keys (optional).
+	SOPSKeys map[string]string `json:"sopsKeys,omitempty"`
+}
+
+type RenderResponse struct {
+	ConfigMap *corev1.ConfigMap `json:"configMap"`
+	Secret    *corev1.Secret    `json:"secret"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(config Config) (*Server, error) {
+	if config.Dir == "" {
+		return nil, &InvalidConfigError{message: "dir must not be empty"}
+	}
+
+	dir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		logger: config.Logger,
+		dir:    dir,
+
+		cachedService: service.NewDynamicService(service.DynamicServiceConfig{
+			Log:   config.Logger,
+			Cache: renderer.NewCache(),
+		}),
+		keysService: service.NewDynamicService(service.DynamicServiceConfig{
+			Log: config.Logger,
+		}),
+
+		mux: http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST /render", s.handleRender)
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	var request RenderRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&request)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	input, err := s.renderInput(request)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The config repository is read through a root confined to it, so neither
+	// variables, layer directories, includes nor extended schemas can read
+	// files outside of it, not even through symbolic links.
+	root, err := os.OpenRoot(s.dir)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer root.Close() // nolint:errcheck
+
+	input.FS = root.FS()
+
+	var configMap *corev1.ConfigMap
+	var secret *corev1.Secret
+
+	if len(request.SOPSKeys) > 0 {
+		configMap, secret, err = s.renderWithKeys(r.Context(), input, request.SOPSKeys)
+	} else {
+		s.sopsMu.RLock()
+		configMap, secret, err = s.cachedService.Render(input)
+		s.sopsMu.RUnlock()
+	}
+	if errors.Is(err, &sopsenv.PgpImportError{}) {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, RenderResponse{ConfigMap: configMap, Secret: secret})
+}
+
+// renderInput validates the request and turns it into the input of the
+// dynamic service.
+func (s *Server) renderInput(request RenderRequest) (service.RenderInput, error) {
+	if request.Schema == "" {
+		return service.RenderInput{}, &InvalidRequestError{message: "schema must not be empty"}
+	}
+	// Requests must not read files outside of the config repository.
+	if !fs.ValidPath(request.Schema) || request.Schema == "." {
+		return service.RenderInput{}, &InvalidRequestError{message: fmt.Sprintf("schema %q must be a relative path inside the config repository", request.Schema)}
+	}
+	if request.Name == "" {
+		return service.RenderInput{}, &InvalidRequestError{message: "name must not be empty"}
+	}
+
+	variables := make([]string, 0, len(request.Variables))
+	for name, value := range request.Variables {
+		// Variables are substituted into the paths of layers and value files,
+		// so they must not point elsewhere.
+		if strings.ContainsAny(value, `/\`) || strings.Contains(value, "..") {
+			return service.RenderInput{}, &InvalidRequestError{message: fmt.Sprintf(`variable %q must not contain "/", "\" or ".."`, name)}
+		}
+
+		variables = append(variables, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(variables)
+
+	input := service.RenderInput{
+		Schema:           request.Schema,
+		Variables:        variables,
+		Name:             request.Name,
+		Namespace:        request.Namespace,
+		ExtraAnnotations: request.ExtraAnnotations,
+		ExtraLabels:      request.ExtraLabels,
+		ConfigMapDataKey: request.ConfigMapDataKey,
+		SecretDataKey:    request.SecretDataKey,
+	}
+
+	if input.Namespace == "" {
+		input.Namespace = defaultNamespace
+	}
+	if input.ConfigMapDataKey == "" {
+		input.ConfigMapDataKey = model.DefaultConfigMapDataKey
+	}
+	if input.SecretDataKey == "" {
+		input.SecretDataKey = model.DefaultSecretDataKey
+	}
+
+	return input, nil
+}
+
+// renderWithKeys renders with SOPS pointed to a temporary directory holding
+// only the given keys. The directory is removed and the SOPS environment of
+// the process restored afterwards.
+func (s *Server) renderWithKeys(ctx context.Context, input service.RenderInput, keys map[string]string) (*corev1.ConfigMap, *corev1.Secret, error) {
+	keysDir, err := os.MkdirTemp("", keysDirPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = os.RemoveAll(keysDir) }()
+
+	sopsEnv, err := sopsenv.NewSOPSEnv(sopsenv.SOPSEnvConfig{
+		KeysDir:    keysDir,
+		KeysSource: key.KeysSourceLocal,
+		Logger:     s.logger,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyData := make(map[string][]byte, len(keys))
+	for name, value := range keys {
+		keyData[name] = []byte(value)
+	}
+
+	s.sopsMu.Lock()
+	defer s.sopsMu.Unlock()
+
+	err = sopsEnv.Setup(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sopsEnv.Cleanup()
+
+	err = sopsEnv.ImportKeys(ctx, keyData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.keysService.Render(input)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.logger.Error(err, "Failed to render", "status", status)
+
+	s.writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		s.logger.Error(err, "Failed to write response")
+	}
+}
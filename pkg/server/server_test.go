@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+// The test data of the dynamic service is served, see pkg/service/testdata.
+const (
+	keysDir    = "../service/testdata/keys"
+	ageKeyFile = keysDir + "/age1q3ed8z5e25t5a2vmzvzsyc9kevd68ukvuvajex0jwhewupat95zsdjmmrw.private"
+	stagesDir  = "../service/testdata/stages"
+)
+
+var stagesVariables = map[string]string{
+	"stage":              "dev",
+	"management-cluster": "mc-1",
+	"konfiguration":      "konfiguration-1",
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Logger: logr.Discard()})
+	if !errors.Is(err, &InvalidConfigError{}) {
+		t.Fatalf("want InvalidConfigError, got: %v", err)
+	}
+}
+
+func TestServer_healthz(t *testing.T) {
+	s, err := New(Config{Logger: logr.Discard(), Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+	defer response.Body.Close() // nolint:errcheck
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("want status %d, got %d", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestServer_render(t *testing.T) {
+	err := testutils.UntarFile(keysDir, "keys.tgz")
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	tmpDir := t.TempDir()
+
+	fs := testutils.NewMockFilesystem(tmpDir, stagesDir+"/cases/case4.yaml")
+
+	schema, err := os.ReadFile(stagesDir + "/schema.yaml")
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	err = os.WriteFile(filepath.Join(tmpDir, "schema.yaml"), schema, 0600)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	// The schema extends a schema outside of the config repository.
+	err = os.WriteFile(filepath.Join(tmpDir, "extends.yaml"), []byte("apiVersion: konfigure.giantswarm.io/v1\nkind: KonfigurationSchema\nextends: ../schema.yaml\n"), 0600)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	ageKey, err := os.ReadFile(ageKeyFile)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	// Keep SOPS away from any keys of the user running the tests, only keys
+	// sent with the requests can decrypt.
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(tmpDir, "missing-keys.txt"))
+	t.Setenv("GNUPGHOME", filepath.Join(tmpDir, "missing-gnupg"))
+
+	s, err := New(Config{Logger: logr.Discard(), Dir: tmpDir})
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		method        string
+		body          string
+		expectedCode  int
+		expectedError string
+	}{
+		{
+			name:         "render with request keys",
+			method:       http.MethodPost,
+			body:         renderRequestBody(t, RenderRequest{Schema: "schema.yaml", Variables: stagesVariables, Name: "app-konfiguration", SOPSKeys: map[string]string{"dev.agekey": string(ageKey)}}),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:          "keys of previous requests are not kept",
+			method:        http.MethodPost,
+			body:          renderRequestBody(t, RenderRequest{Schema: "schema.yaml", Variables: stagesVariables, Name: "app-konfiguration"}),
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: "Error getting data key",
+		},
+		{
+			name:          "schema outside of dir",
+			method:        http.MethodPost,
+			body:          renderRequestBody(t, RenderRequest{Schema: "../schema.yaml", Variables: stagesVariables, Name: "app-konfiguration"}),
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "must be a relative path inside the config repository",
+		},
+		{
+			name:          "variable leaving dir",
+			method:        http.MethodPost,
+			body:          renderRequestBody(t, RenderRequest{Schema: "schema.yaml", Variables: map[string]string{"stage": "../../..", "management-cluster": "mc-1", "konfiguration": "konfiguration-1"}, Name: "app-konfiguration"}),
+			expectedCode:  http.StatusBadRequest,
+			expectedError: `variable "stage" must not contain`,
+		},
+		{
+			name:          "extended schema outside of dir",
+			method:        http.MethodPost,
+			body:          renderRequestBody(t, RenderRequest{Schema: "extends.yaml", Variables: stagesVariables, Name: "app-konfiguration"}),
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedError: "invalid argument",
+		},
+		{
+			name:          "missing name",
+			method:        http.MethodPost,
+			body:          renderRequestBody(t, RenderRequest{Schema: "schema.yaml", Variables: stagesVariables}),
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "name must not be empty",
+		},
+		{
+			name:          "unknown field",
+			method:        http.MethodPost,
+			body:          `{"schema": "schema.yaml", "name": "app-konfiguration", "dir": "/"}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedError: "invalid request body",
+		},
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("case %d: %s", i, tc.name), func(t *testing.T) {
+			request, err := http.NewRequest(tc.method, server.URL+"/render", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+			defer response.Body.Close() // nolint:errcheck
+
+			body, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			if response.StatusCode != tc.expectedCode {
+				t.Fatalf("want status %d, got %d: %s", tc.expectedCode, response.StatusCode, body)
+			}
+
+			if tc.expectedError != "" {
+				var errorResponse ErrorResponse
+
+				err = json.Unmarshal(body, &errorResponse)
+				if err != nil {
+					t.Fatalf("want nil, got error: %s", err)
+				}
+
+				if !strings.Contains(errorResponse.Error, tc.expectedError) {
+					t.Fatalf("want error containing %q, got %q", tc.expectedError, errorResponse.Error)
+				}
+			}
+
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var renderResponse RenderResponse
+
+			err = json.Unmarshal(body, &renderResponse)
+			if err != nil {
+				t.Fatalf("want nil, got error: %s", err)
+			}
+
+			configMap := renderResponse.ConfigMap
+			if configMap.Name != "app-konfiguration" || configMap.Namespace != "default" {
+				t.Fatalf("want ConfigMap default/app-konfiguration, got %s/%s", configMap.Namespace, configMap.Name)
+			}
+			if configMap.Data[model.DefaultConfigMapDataKey] != fs.ExpectedConfigmap {
+				t.Fatalf("configmap not expected, got: %s, expected: %s", configMap.Data[model.DefaultConfigMapDataKey], fs.ExpectedConfigmap)
+			}
+
+			secret := renderResponse.Secret
+			if string(secret.Data[model.DefaultSecretDataKey]) != fs.ExpectedSecret {
+				t.Fatalf("secret not expected, got: %s, expected: %s", secret.Data[model.DefaultSecretDataKey], fs.ExpectedSecret)
+			}
+		})
+	}
+
+	// Keys of requests must not leak into the environment of the process.
+	if os.Getenv("SOPS_AGE_KEY_FILE") != filepath.Join(tmpDir, "missing-keys.txt") {
+		t.Fatalf("want SOPS_AGE_KEY_FILE restored, got %q", os.Getenv("SOPS_AGE_KEY_FILE"))
+	}
+}
+
+func renderRequestBody(t *testing.T, request RenderRequest) string {
+	var body bytes.Buffer
+
+	err := json.NewEncoder(&body).Encode(request)
+	if err != nil {
+		t.Fatalf("want nil, got error: %s", err)
+	}
+
+	return body.String()
+}
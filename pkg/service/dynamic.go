@@ -1,28 +1,57 @@
 package service
 
 import (
+	"io/fs"
+
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 
+	"github.com/giantswarm/konfigure/v2/pkg/model"
 	"github.com/giantswarm/konfigure/v2/pkg/renderer"
 )
 
 type DynamicServiceConfig struct {
 	Log logr.Logger
+
+	// StageObserver receives the intermediate results of rendering every
+	// layer, optional. It is called concurrently when RenderAll renders with
+	// several workers.
+	StageObserver StageObserver
+
+	// ShowSecretStages disables redacting stages containing secrets passed
+	// to the StageObserver.
+	ShowSecretStages bool
+
+	// Cache for decrypted files and parsed templates shared by all renders
+	// of the service, optional.
+	Cache *renderer.Cache
 }
 
 type DynamicService struct {
-	log logr.Logger
+	log   logr.Logger
+	cache *renderer.Cache
+
+	stageObserver    StageObserver
+	showSecretStages bool
 }
 
 func NewDynamicService(config DynamicServiceConfig) *DynamicService {
-	return &DynamicService{log: config.Log}
+	return &DynamicService{
+		log:              config.Log,
+		cache:            config.Cache,
+		stageObserver:    config.StageObserver,
+		showSecretStages: config.ShowSecretStages,
+	}
 }
 
 type RenderInput struct {
 	// Root directory of the config repository.
 	Dir string
 
+	// Filesystem of the config repository, used instead of Dir if set. The
+	// schema is loaded from the filesystem as well then.
+	FS fs.FS
+
 	// Path to the schema file.
 	Schema string
 
@@ -49,7 +78,12 @@ type RenderInput struct {
 }
 
 func (s *DynamicService) Render(in RenderInput) (configmap *corev1.ConfigMap, secret *corev1.Secret, err error) {
-	configmapData, secretData, err := s.RenderRaw(in.Dir, in.Schema, in.Variables)
+	source := dirSource(in.Dir)
+	if in.FS != nil {
+		source = fsSource(in.FS)
+	}
+
+	configmapData, secretData, err := s.renderRaw(source, in.Schema, in.Variables, s.cache)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -63,12 +97,116 @@ func (s *DynamicService) Render(in RenderInput) (configmap *corev1.ConfigMap, se
 }
 
 func (s *DynamicService) RenderRaw(dir, schema string, primitiveVariables []string) (configmapData string, secretData string, err error) {
+	return s.renderRaw(dirSource(dir), schema, primitiveVariables, s.cache)
+}
+
+// RenderRawFS works like RenderRaw, but loads the schema at the slash separated
+// path and the files of the config repository from the filesystem.
+func (s *DynamicService) RenderRawFS(fsys fs.FS, schema string, primitiveVariables []string) (configmapData string, secretData string, err error) {
+	return s.renderRaw(fsSource(fsys), schema, primitiveVariables, s.cache)
+}
+
+// RenderRawFiles works like RenderRaw, but reads the value files, templates,
+// includes and patches of the config repository from the filesystem, e.g. to
+// track which files are used. The schema is loaded from the OS path.
+func (s *DynamicService) RenderRawFiles(files fs.FS, schema string, primitiveVariables []string) (configmapData string, secretData string, err error) {
+	source := renderSource{
+		fsys:       files,
+		loadSchema: renderer.LoadSchema,
+	}
+
+	return s.renderRaw(source, schema, primitiveVariables, s.cache)
+}
+
+func (s *DynamicService) renderRaw(source renderSource, schema string, primitiveVariables []string, cache *renderer.Cache) (configmapData string, secretData string, err error) {
+	state, err := s.render(source, schema, primitiveVariables, cache)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.log.Info("Folding and applying patches to rendered templates...")
+
+	configmapData, secretData, steps, err := renderer.FoldAndPatchRenderedTemplatesWithSteps(state.schema, state.renderedTemplates, state.patches)
+	if err != nil {
+		s.log.Error(err, "Failed to fold and apply patches to rendered templates")
+		return "", "", err
+	}
+
+	if s.stageObserver != nil {
+		err = observeStages(s.stageObserver, s.showSecretStages, state.schema, state.renderedTemplates, steps)
+		if err != nil {
+			s.log.Error(err, "Failed to observe rendering stages")
+			return "", "", err
+		}
+	}
+
+	return configmapData, secretData, nil
+}
+
+// Explain renders the schema like RenderRaw and traces which layers, files and
+// operations set the value at the path of the rendered ConfigMap or Secret.
+func (s *DynamicService) Explain(dir, schema string, primitiveVariables []string, valueType model.ValueMergeReferenceType, path string) (*renderer.Explanation, error) {
+	state, err := s.render(dirSource(dir), schema, primitiveVariables, s.cache)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("Explaining path...", "path", path, "type", valueType)
+
+	explanation, err := renderer.ExplainPath(state.schema, state.valueFiles, state.templates, state.renderedTemplates, state.patches, valueType, path)
+	if err != nil {
+		s.log.Error(err, "Failed to explain path", "path", path)
+		return nil, err
+	}
+
+	return explanation, nil
+}
+
+// renderState holds everything loaded and rendered for a schema, before the
+// rendered templates are folded.
+type renderState struct {
+	schema            *model.Schema
+	valueFiles        *renderer.ValueFiles
+	templates         *renderer.Templates
+	renderedTemplates *renderer.RenderedTemplates
+	patches           *renderer.Patches
+}
+
+// renderSource is the config repository a schema is rendered from.
+type renderSource struct {
+	fsys       fs.FS
+	loadSchema func(path string) (*model.Schema, error)
+}
+
+// dirSource loads the schema and the files of the config repository from the
+// OS filesystem.
+func dirSource(dir string) renderSource {
+	return renderSource{
+		fsys:       renderer.LegacyDirFS(dir),
+		loadSchema: renderer.LoadSchema,
+	}
+}
+
+// fsSource loads the schema and the files of the config repository from the
+// filesystem.
+func fsSource(fsys fs.FS) renderSource {
+	return renderSource{
+		fsys: fsys,
+		loadSchema: func(path string) (*model.Schema, error) {
+			return renderer.LoadSchemaFS(fsys, path)
+		},
+	}
+}
+
+// render loads and renders the schema, decrypting files and parsing templates
+// through the cache. The cache is optional.
+func (s *DynamicService) render(source renderSource, schema string, primitiveVariables []string, cache *renderer.Cache) (*renderState, error) {
 	s.log.Info("Loading schema...")
 
-	parsedSchema, err := renderer.LoadSchema(schema)
+	parsedSchema, err := source.loadSchema(schema)
 	if err != nil {
 		s.log.Error(err, "Failed to load schema", "file", schema)
-		return "", "", err
+		return nil, err
 	}
 
 	s.log.Info("Loading values for schema variables...")
@@ -76,48 +214,58 @@ func (s *DynamicService) RenderRaw(dir, schema string, primitiveVariables []stri
 	parsedSchemaVariables, err := renderer.LoadSchemaVariables(primitiveVariables, parsedSchema.Variables)
 	if err != nil {
 		s.log.Error(err, "Failed to load values for schema variables", "schema", schema, "variables", primitiveVariables)
-		return "", "", err
+		return nil, err
+	}
+
+	s.log.Info("Resolving layer conditions...")
+
+	parsedSchema, skippedLayers, err := renderer.ResolveLayers(parsedSchema, parsedSchemaVariables)
+	if err != nil {
+		s.log.Error(err, "Failed to resolve layer conditions", "schema", schema)
+		return nil, err
+	}
+
+	for _, layer := range skippedLayers {
+		s.log.Info("Skipping layer, its condition is not met", "layer", layer.Id, "when", layer.When)
 	}
 
 	s.log.Info("Loading value files...")
 
-	valueFiles, err := renderer.LoadValueFiles(dir, parsedSchema, parsedSchemaVariables)
+	valueFiles, err := renderer.LoadValueFilesFS(source.fsys, parsedSchema, parsedSchemaVariables, cache)
 	if err != nil {
 		s.log.Error(err, "Failed to load value files")
-		return "", "", err
+		return nil, err
 	}
 
 	s.log.Info("Loading templates...")
 
-	loadedTemplates, err := renderer.LoadTemplates(dir, parsedSchema, parsedSchemaVariables)
+	loadedTemplates, err := renderer.LoadTemplatesFS(source.fsys, parsedSchema, parsedSchemaVariables, cache)
 	if err != nil {
 		s.log.Error(err, "Failed to load templates")
-		return "", "", err
+		return nil, err
 	}
 
 	s.log.Info("Rendering templates...")
 
-	renderedTemplates, err := renderer.RenderTemplates(dir, parsedSchema, loadedTemplates, valueFiles)
+	renderedTemplates, err := renderer.RenderTemplatesFS(source.fsys, parsedSchema, loadedTemplates, valueFiles, parsedSchemaVariables, cache)
 	if err != nil {
 		s.log.Error(err, "Failed to render templates")
-		return "", "", err
+		return nil, err
 	}
 
 	s.log.Info("Loading patches...")
 
-	loadedPatches, err := renderer.LoadPatches(dir, parsedSchema, parsedSchemaVariables)
+	loadedPatches, err := renderer.LoadPatchesFS(source.fsys, parsedSchema, parsedSchemaVariables)
 	if err != nil {
 		s.log.Error(err, "Failed to load patches")
-		return "", "", err
+		return nil, err
 	}
 
-	s.log.Info("Folding and applying patches to rendered templates...")
-
-	configmapData, secretData, err = renderer.FoldAndPatchRenderedTemplates(parsedSchema, renderedTemplates, loadedPatches)
-	if err != nil {
-		s.log.Error(err, "Failed to fold and apply patches to rendered templates")
-		return "", "", err
-	}
-
-	return configmapData, secretData, nil
+	return &renderState{
+		schema:            parsedSchema,
+		valueFiles:        valueFiles,
+		templates:         loadedTemplates,
+		renderedTemplates: renderedTemplates,
+		patches:           loadedPatches,
+	}, nil
 }
@@ -256,6 +256,32 @@ func TestRenderRaw_Stages(t *testing.T) {
 
 			expectedErrorMessage: "0-base/konfiguration-1/config-map-template.yaml does not exist",
 		},
+		{
+			name:     "case 7 - use schema variables in templates and included files",
+			caseFile: "testdata/stages/cases/case7.yaml",
+
+			schema: "testdata/stages/schema.yaml",
+
+			rawVariables: []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"},
+		},
+		{
+			name:     "case 8 - error on undeclared variable in template",
+			caseFile: "testdata/stages/cases/case8.yaml",
+
+			schema: "testdata/stages/schema.yaml",
+
+			rawVariables: []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"},
+
+			expectedErrorMessage: `variable "cluster" is not declared in the schema`,
+		},
+		{
+			name:     "case 9 - values overridden by later layers and patches",
+			caseFile: "testdata/stages/cases/case9.yaml",
+
+			schema: "testdata/stages/schema.yaml",
+
+			rawVariables: []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+func TestExplain_Stages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		caseFile string
+
+		path string
+
+		expectedFound bool
+		expectedValue interface{}
+		expectedSteps []renderer.ExplanationStep
+	}{
+		{
+			name:          "case 0 - value set by value files, template and patch",
+			caseFile:      "testdata/stages/cases/case9.yaml",
+			path:          "app.replicas",
+			expectedFound: true,
+			expectedValue: json.Number("5"),
+			expectedSteps: []renderer.ExplanationStep{
+				{
+					Layer:     "base",
+					File:      "0-base/values.yaml",
+					Operation: renderer.ExplanationOperationValueMerge,
+					Detail:    "ConfigMap values for the template of layer base",
+					Value:     json.Number("1"),
+				},
+				{
+					Layer:     "management-cluster",
+					File:      "2-management-clusters/mc-1/values.yaml",
+					Operation: renderer.ExplanationOperationValueMerge,
+					Detail:    "ConfigMap values for the template of layer base",
+					Value:     json.Number("3"),
+				},
+				{
+					Layer:     "base",
+					File:      "0-base/konfiguration-1/config-map-template.yaml",
+					Operation: renderer.ExplanationOperationTemplateMerge,
+					Value:     json.Number("3"),
+				},
+				{
+					Layer:     "stages",
+					File:      "1-stages/konfigurations/konfiguration-1/config-map-patches-dev.yaml",
+					Operation: renderer.ExplanationOperationPatch,
+					Detail:    "replace /app/replicas",
+					Value:     json.Number("5"),
+				},
+			},
+		},
+		{
+			name:          "case 1 - value added by a patch",
+			caseFile:      "testdata/stages/cases/case2.yaml",
+			path:          "/a/h/2",
+			expectedFound: true,
+			expectedValue: "added by patch",
+			expectedSteps: []renderer.ExplanationStep{
+				{
+					Layer:     "stages",
+					File:      "1-stages/konfigurations/konfiguration-1/config-map-patches-dev.yaml",
+					Operation: renderer.ExplanationOperationPatch,
+					Detail:    "add /a/h/-",
+					Value:     "added by patch",
+				},
+			},
+		},
+		{
+			name:          "case 2 - value removed by a patch",
+			caseFile:      "testdata/stages/cases/case2.yaml",
+			path:          "a.b.d",
+			expectedFound: false,
+			expectedSteps: []renderer.ExplanationStep{
+				{
+					Layer:     "base",
+					File:      "0-base/konfiguration-1/config-map-template.yaml",
+					Operation: renderer.ExplanationOperationTemplateMerge,
+					Value:     "hello world",
+				},
+				{
+					Layer:     "stages",
+					File:      "1-stages/konfigurations/konfiguration-1/config-map-patches-dev.yaml",
+					Operation: renderer.ExplanationOperationPatch,
+					Detail:    "remove /a/b",
+					Removed:   true,
+				},
+			},
+		},
+		{
+			name:          "case 3 - list overridden by a later layer",
+			caseFile:      "testdata/stages/cases/case2.yaml",
+			path:          "a.f.g[1]",
+			expectedFound: true,
+			expectedValue: "e",
+			expectedSteps: []renderer.ExplanationStep{
+				{
+					Layer:     "base",
+					File:      "0-base/konfiguration-1/config-map-template.yaml",
+					Operation: renderer.ExplanationOperationTemplateMerge,
+					Value:     "b",
+				},
+				{
+					Layer:     "management-cluster",
+					File:      "2-management-clusters/mc-1/konfiguration-1/config-map-template.yaml",
+					Operation: renderer.ExplanationOperationTemplateMerge,
+					Value:     "e",
+				},
+			},
+		},
+	}
+
+	service := NewDynamicService(DynamicServiceConfig{
+		Log: logr.Discard(),
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			_ = testutils.NewMockFilesystem(tmpDir, tc.caseFile)
+
+			explanation, err := service.Explain(
+				tmpDir,
+				"testdata/stages/schema.yaml",
+				[]string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"},
+				model.ValueMergeReferenceTypeConfigMap,
+				tc.path,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if explanation.Found != tc.expectedFound || !reflect.DeepEqual(explanation.Value, tc.expectedValue) {
+				t.Errorf("Expected value %v (found: %t), got %v (found: %t)", tc.expectedValue, tc.expectedFound, explanation.Value, explanation.Found)
+			}
+
+			if !reflect.DeepEqual(explanation.Steps, tc.expectedSteps) {
+				t.Errorf("Expected steps %+v, got %+v", tc.expectedSteps, explanation.Steps)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/filesystem"
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+func TestRenderRawFS(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_ = testutils.NewMockFilesystem(tmpDir, "testdata/stages/cases/case9.yaml")
+
+	// Copy the config repository and the schema into an in-memory filesystem.
+	fsys := fstest.MapFS{}
+
+	dirFS := os.DirFS(tmpDir)
+
+	err := fs.WalkDir(dirFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(dirFS, path)
+		if err != nil {
+			return err
+		}
+
+		fsys[path] = &fstest.MapFile{Data: data}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := os.ReadFile("testdata/stages/schema.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["schemas/stages.yaml"] = &fstest.MapFile{Data: schema}
+
+	service := NewDynamicService(DynamicServiceConfig{
+		Log: logr.Discard(),
+	})
+
+	variables := []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"}
+
+	expectedConfigMap, expectedSecret, err := service.RenderRaw(tmpDir, "testdata/stages/schema.yaml", variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	configMap, secret, err := service.RenderRawFS(fsys, "schemas/stages.yaml", variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if configMap != expectedConfigMap {
+		t.Errorf("Expected ConfigMap %q, got %q", expectedConfigMap, configMap)
+	}
+
+	if secret != expectedSecret {
+		t.Errorf("Expected Secret %q, got %q", expectedSecret, secret)
+	}
+
+	if configMap == "" {
+		t.Errorf("Expected a rendered ConfigMap")
+	}
+}
+
+func TestRenderRawFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_ = testutils.NewMockFilesystem(tmpDir, "testdata/stages/cases/case9.yaml")
+
+	service := NewDynamicService(DynamicServiceConfig{
+		Log: logr.Discard(),
+	})
+
+	variables := []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"}
+
+	expectedConfigMap, expectedSecret, err := service.RenderRaw(tmpDir, "testdata/stages/schema.yaml", variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	trackingFS := filesystem.NewTrackingFS(os.DirFS(tmpDir))
+
+	configMap, secret, err := service.RenderRawFiles(trackingFS, "testdata/stages/schema.yaml", variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if configMap != expectedConfigMap {
+		t.Errorf("Expected ConfigMap %q, got %q", expectedConfigMap, configMap)
+	}
+
+	if secret != expectedSecret {
+		t.Errorf("Expected Secret %q, got %q", expectedSecret, secret)
+	}
+
+	if !trackingFS.Uses("0-base/values.yaml") {
+		t.Errorf("Expected 0-base/values.yaml to be used by the render")
+	}
+
+	if !trackingFS.Uses("1-stages/stages/dev/values.yaml") {
+		t.Errorf("Expected 1-stages/stages/dev/values.yaml to be used by the render")
+	}
+
+	if trackingFS.Uses("2-management-clusters/mc-2/values.yaml") {
+		t.Errorf("Expected files of other management clusters not to be used by the render")
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	yaml3 "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+)
+
+// DefaultRenderMatrixNamespace is the namespace of render matrix entries that
+// do not set one.
+const DefaultRenderMatrixNamespace = "default"
+
+type RenderAllInput struct {
+	// Root directory of the config repository.
+	Dir string
+
+	// Filesystem of the config repository, used instead of Dir if set. The
+	// schema is loaded from the filesystem as well then.
+	FS fs.FS
+
+	// Path to the schema file.
+	Schema string
+
+	// The variable sets to render the schema with.
+	Matrix *model.RenderMatrix
+
+	// The key to store the rendered data in the generated ConfigMaps
+	ConfigMapDataKey string
+
+	// The key to store the rendered data in the generated Secrets
+	SecretDataKey string
+
+	// The number of entries rendered in parallel, defaults to 1.
+	Workers int
+}
+
+type RenderAllResult struct {
+	Entry     model.RenderMatrixEntry
+	ConfigMap *corev1.ConfigMap
+	Secret    *corev1.Secret
+}
+
+// RenderAllHandler receives the result of every entry of the render matrix.
+type RenderAllHandler func(RenderAllResult) error
+
+// LoadRenderMatrix loads the render matrix file, sets the defaults of its
+// entries and validates them.
+func LoadRenderMatrix(path string) (*model.RenderMatrix, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var matrix model.RenderMatrix
+
+	decoder := yaml3.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&matrix)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, &InvalidConfigError{message: fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	if len(matrix.Entries) == 0 {
+		return nil, &InvalidConfigError{message: fmt.Sprintf("%s: entries must not be empty", path)}
+	}
+
+	outputs := make(map[string]string)
+
+	for i := range matrix.Entries {
+		entry := &matrix.Entries[i]
+
+		if entry.Name == "" {
+			return nil, &InvalidConfigError{message: fmt.Sprintf("%s: entries[%d]: name must not be empty", path, i)}
+		}
+		if entry.Namespace == "" {
+			entry.Namespace = DefaultRenderMatrixNamespace
+		}
+		if entry.Output == "" {
+			entry.Output = entry.Name + ".yaml"
+		}
+
+		if !filepath.IsLocal(entry.Output) {
+			return nil, &InvalidConfigError{message: fmt.Sprintf("%s: entries[%d]: output %q must be a relative path within the output directory", path, i, entry.Output)}
+		}
+
+		output := filepath.Clean(entry.Output)
+		if other, found := outputs[output]; found {
+			return nil, &InvalidConfigError{message: fmt.Sprintf("%s: entries[%d]: output %q is already used by entry %s, set a distinct output", path, i, entry.Output, other)}
+		}
+		outputs[output] = entry.Name
+	}
+
+	return &matrix, nil
+}
+
+// RenderAll renders the schema for every entry of the matrix, rendering up to
+// in.Workers entries in parallel. Decrypted files and parsed templates are
+// shared by all entries, through the cache of the service if it has one.
+// Results are passed to the handler one at a time in the order of the
+// entries, rendering stops at the first failed entry.
+func (s *DynamicService) RenderAll(in RenderAllInput, handler RenderAllHandler) error {
+	cache := s.cache
+	if cache == nil {
+		cache = renderer.NewCache()
+	}
+
+	workers := in.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	source := dirSource(in.Dir)
+	if in.FS != nil {
+		source = fsSource(in.FS)
+	}
+
+	entries := in.Matrix.Entries
+
+	// Every entry gets a buffered channel, so workers never block on entries
+	// that are not handled yet.
+	results := make([]chan renderAllOutcome, len(entries))
+	for i := range results {
+		results[i] = make(chan renderAllOutcome, 1)
+	}
+
+	indexes := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(indexes)
+
+		for i := range entries {
+			select {
+			case indexes <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indexes {
+				results[i] <- s.renderEntry(in, source, entries[i], cache)
+			}
+		}()
+	}
+
+	for i := range entries {
+		outcome := <-results[i]
+		if outcome.err != nil {
+			return outcome.err
+		}
+
+		err := handler(outcome.result)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type renderAllOutcome struct {
+	result RenderAllResult
+	err    error
+}
+
+func (s *DynamicService) renderEntry(in RenderAllInput, source renderSource, entry model.RenderMatrixEntry, cache *renderer.Cache) renderAllOutcome {
+	s.log.Info("Rendering matrix entry...", "name", entry.Name, "namespace", entry.Namespace)
+
+	configmapData, secretData, err := s.renderRaw(source, in.Schema, entryVariables(in.Matrix, entry), cache)
+	if err != nil {
+		return renderAllOutcome{err: fmt.Errorf("failed to render entry %s: %w", entry.Name, err)}
+	}
+
+	return renderAllOutcome{
+		result: RenderAllResult{
+			Entry:     entry,
+			ConfigMap: renderer.WrapIntoConfigMap(configmapData, entry.Name, entry.Namespace, nil, nil, in.ConfigMapDataKey),
+			Secret:    renderer.WrapIntoSecret(secretData, entry.Name, entry.Namespace, nil, nil, in.SecretDataKey),
+		},
+	}
+}
+
+// entryVariables returns the variables of the matrix overridden by the ones of
+// the entry in primitive format of 'name=value'.
+func entryVariables(matrix *model.RenderMatrix, entry model.RenderMatrixEntry) []string {
+	variables := make(map[string]string, len(matrix.Variables)+len(entry.Variables))
+	for name, value := range matrix.Variables {
+		variables[name] = value
+	}
+	for name, value := range entry.Variables {
+		variables[name] = value
+	}
+
+	primitiveVariables := make([]string, 0, len(variables))
+	for name, value := range variables {
+		primitiveVariables = append(primitiveVariables, name+"="+value)
+	}
+
+	sort.Strings(primitiveVariables)
+
+	return primitiveVariables
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+func TestLoadRenderMatrix(t *testing.T) {
+	testCases := []struct {
+		name   string
+		matrix string
+
+		expectedMatrix *model.RenderMatrix
+		expectedError  string
+	}{
+		{
+			name: "case 0 - defaults are set",
+			matrix: `variables:
+  stage: dev
+entries:
+  - name: app-1
+    variables:
+      app: app-1
+  - name: app-2
+    namespace: giantswarm
+    output: mc-1/app-2.yaml
+`,
+			expectedMatrix: &model.RenderMatrix{
+				Variables: map[string]string{"stage": "dev"},
+				Entries: []model.RenderMatrixEntry{
+					{Name: "app-1", Namespace: "default", Output: "app-1.yaml", Variables: map[string]string{"app": "app-1"}},
+					{Name: "app-2", Namespace: "giantswarm", Output: "mc-1/app-2.yaml"},
+				},
+			},
+		},
+		{
+			name:          "case 1 - entries are required",
+			matrix:        ``,
+			expectedError: "entries must not be empty",
+		},
+		{
+			name: "case 2 - names are required",
+			matrix: `entries:
+  - namespace: giantswarm
+`,
+			expectedError: "entries[0]: name must not be empty",
+		},
+		{
+			name: "case 3 - outputs must be distinct",
+			matrix: `entries:
+  - name: app-1
+    namespace: a
+  - name: app-1
+    namespace: b
+`,
+			expectedError: `entries[1]: output "app-1.yaml" is already used by entry app-1`,
+		},
+		{
+			name: "case 4 - outputs must stay within the output directory",
+			matrix: `entries:
+  - name: app-1
+    output: ../app-1.yaml
+`,
+			expectedError: `entries[0]: output "../app-1.yaml" must be a relative path within the output directory`,
+		},
+		{
+			name: "case 5 - unknown fields are rejected",
+			matrix: `entries:
+  - name: app-1
+    variable:
+      app: app-1
+`,
+			expectedError: "field variable not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "matrix.yaml")
+
+			err := os.WriteFile(path, []byte(tc.matrix), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			matrix, err := LoadRenderMatrix(path)
+			if tc.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tc.expectedError, err)
+				}
+				if !errors.Is(err, &InvalidConfigError{}) {
+					t.Fatalf("expected InvalidConfigError, got %T", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(matrix, tc.expectedMatrix) {
+				t.Fatalf("matrix = %#v, want %#v", matrix, tc.expectedMatrix)
+			}
+		})
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			testRenderAll(t, workers)
+		})
+	}
+}
+
+func testRenderAll(t *testing.T, workers int) {
+	tmpDir := t.TempDir()
+
+	_ = testutils.NewMockFilesystem(tmpDir, "testdata/stages/cases/case9.yaml")
+
+	matrix := &model.RenderMatrix{
+		Variables: map[string]string{
+			"stage":              "dev",
+			"management-cluster": "mc-1",
+			"konfiguration":      "konfiguration-1",
+		},
+		Entries: []model.RenderMatrixEntry{
+			{Name: "app-1", Namespace: "giantswarm", Output: "app-1.yaml"},
+			{Name: "app-2", Namespace: "default", Output: "app-2.yaml", Variables: map[string]string{"stage": "production"}},
+			{Name: "app-3", Namespace: "default", Output: "app-3.yaml", Variables: map[string]string{"konfiguration": "konfiguration-2"}},
+		},
+	}
+
+	service := NewDynamicService(DynamicServiceConfig{
+		Log: logr.Discard(),
+	})
+
+	var results []RenderAllResult
+
+	err := service.RenderAll(RenderAllInput{
+		Dir:              tmpDir,
+		Schema:           "testdata/stages/schema.yaml",
+		Matrix:           matrix,
+		ConfigMapDataKey: "configmap-values.yaml",
+		SecretDataKey:    "secret-values.yaml",
+		Workers:          workers,
+	}, func(result RenderAllResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to render entry app-3") {
+		t.Fatalf("expected error rendering entry app-3 without templates, got %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results before the failed entry, got %d", len(results))
+	}
+
+	expectedConfigMaps := []struct {
+		name      string
+		namespace string
+		data      string
+	}{
+		{name: "app-1", namespace: "giantswarm", data: "app:\n  name: base\n  replicas: 5\n"},
+		{name: "app-2", namespace: "default", data: "app:\n  name: base\n  replicas: 3\n"},
+	}
+
+	for i, expected := range expectedConfigMaps {
+		configMap := results[i].ConfigMap
+
+		if configMap.Name != expected.name || configMap.Namespace != expected.namespace {
+			t.Fatalf("ConfigMap = %s/%s, want %s/%s", configMap.Namespace, configMap.Name, expected.namespace, expected.name)
+		}
+
+		if data := configMap.Data["configmap-values.yaml"]; data != expected.data {
+			t.Fatalf("ConfigMap %s data = %q, want %q", expected.name, data, expected.data)
+		}
+
+		if secret := results[i].Secret; secret.Name != expected.name || secret.Namespace != expected.namespace {
+			t.Fatalf("Secret = %s/%s, want %s/%s", secret.Namespace, secret.Name, expected.namespace, expected.name)
+		}
+	}
+}
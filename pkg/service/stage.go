@@ -0,0 +1,104 @@
+package service
+
+import (
+	"github.com/giantswarm/konfigure/v2/pkg/model"
+	"github.com/giantswarm/konfigure/v2/pkg/renderer"
+	"github.com/giantswarm/konfigure/v2/pkg/utils"
+)
+
+const (
+	// StageConfigMapValues is the merged value context the ConfigMap template
+	// of a layer is rendered with.
+	StageConfigMapValues = "ConfigMapValues"
+	// StageSecretValues is the merged value context the Secret template of a
+	// layer is rendered with.
+	StageSecretValues = "SecretValues"
+	// StageConfigMapTemplate is the rendered ConfigMap template of a layer.
+	StageConfigMapTemplate = "ConfigMapTemplate"
+	// StageSecretTemplate is the rendered Secret template of a layer.
+	StageSecretTemplate = "SecretTemplate"
+	// StageConfigMapMerged is the folded ConfigMap after merging the rendered
+	// ConfigMap template of a layer.
+	StageConfigMapMerged = "ConfigMapMerged"
+	// StageSecretMerged is the folded Secret after merging the rendered Secret
+	// template of a layer.
+	StageSecretMerged = "SecretMerged"
+	// StageConfigMapPatched is the folded ConfigMap after applying the
+	// ConfigMap patches of a layer.
+	StageConfigMapPatched = "ConfigMapPatched"
+	// StageSecretPatched is the folded Secret after applying the Secret
+	// patches of a layer.
+	StageSecretPatched = "SecretPatched"
+)
+
+// Stage is an intermediate result of rendering a layer.
+type Stage struct {
+	Layer string `json:"layer" yaml:"layer"`
+	Stage string `json:"stage" yaml:"stage"`
+
+	// Redacted is true if the values of the data were redacted because they
+	// contain secrets.
+	Redacted bool `json:"redacted,omitempty" yaml:"redacted,omitempty"`
+
+	Data string `json:"data" yaml:"data"`
+}
+
+// StageObserver receives the stages of rendering in order.
+type StageObserver func(stage Stage) error
+
+// observeStages passes the stages of every layer to the observer: the value
+// contexts, the rendered templates, and the folded results after merging and
+// after patching. Stages containing secrets are redacted unless showSecrets
+// is set.
+func observeStages(observer StageObserver, showSecrets bool, schema *model.Schema, renderedTemplates *renderer.RenderedTemplates, steps []renderer.FoldStep) error {
+	for i, layer := range schema.Layers {
+		// The ConfigMap template can be rendered with secret values too,
+		// depending on the merge strategy.
+		configMapValuesContainSecrets := false
+
+		references, err := renderer.ValueFileReferences(schema, layer, model.ValueMergeReferenceTypeConfigMap)
+		if err != nil {
+			return err
+		}
+
+		for _, reference := range references {
+			if reference.Type == model.ValueMergeReferenceTypeSecret {
+				configMapValuesContainSecrets = true
+			}
+		}
+
+		stages := []struct {
+			name    string
+			data    string
+			secrets bool
+		}{
+			{StageConfigMapValues, renderedTemplates.ConfigMapValues[layer.Id], configMapValuesContainSecrets},
+			{StageSecretValues, renderedTemplates.SecretValues[layer.Id], true},
+			{StageConfigMapTemplate, renderedTemplates.ConfigMaps[layer.Id], false},
+			{StageSecretTemplate, renderedTemplates.Secrets[layer.Id], true},
+			{StageConfigMapMerged, steps[i].ConfigMapMerged, false},
+			{StageSecretMerged, steps[i].SecretMerged, true},
+			{StageConfigMapPatched, steps[i].ConfigMapPatched, false},
+			{StageSecretPatched, steps[i].SecretPatched, true},
+		}
+
+		for _, stage := range stages {
+			redacted := stage.secrets && !showSecrets
+
+			data := stage.data
+			if redacted {
+				data, err = utils.RedactYAMLValues(data)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = observer(Stage{Layer: layer.Id, Stage: stage.name, Redacted: redacted, Data: data})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
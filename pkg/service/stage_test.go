@@ -0,0 +1,98 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/giantswarm/konfigure/v2/pkg/testutils"
+)
+
+func TestRenderRaw_StageObserver(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		showSecretStages bool
+
+		expectedStages map[string]Stage
+	}{
+		{
+			name: "case 0 - secrets are redacted",
+			expectedStages: map[string]Stage{
+				"base/" + StageConfigMapValues: {
+					Layer: "base",
+					Stage: StageConfigMapValues,
+					Data:  "app:\n    name: base\n    replicas: 3\n",
+				},
+				"base/" + StageSecretValues: {
+					Layer:    "base",
+					Stage:    StageSecretValues,
+					Redacted: true,
+					Data:     "app:\n  name: <redacted>\n  replicas: <redacted>\npassword: <redacted>\n",
+				},
+				"stages/" + StageConfigMapMerged: {
+					Layer: "stages",
+					Stage: StageConfigMapMerged,
+					Data:  "app:\n    name: base\n    replicas: 3\n",
+				},
+				"stages/" + StageConfigMapPatched: {
+					Layer: "stages",
+					Stage: StageConfigMapPatched,
+					Data:  "app:\n  name: base\n  replicas: 5\n",
+				},
+			},
+		},
+		{
+			name:             "case 1 - secrets are shown",
+			showSecretStages: true,
+			expectedStages: map[string]Stage{
+				"base/" + StageSecretValues: {
+					Layer: "base",
+					Stage: StageSecretValues,
+					Data:  "app:\n    name: base\n    replicas: 3\npassword: hunter2\n",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			_ = testutils.NewMockFilesystem(tmpDir, "testdata/stages/cases/case9.yaml")
+
+			var stages []Stage
+
+			service := NewDynamicService(DynamicServiceConfig{
+				Log: logr.Discard(),
+				StageObserver: func(stage Stage) error {
+					stages = append(stages, stage)
+					return nil
+				},
+				ShowSecretStages: tc.showSecretStages,
+			})
+
+			_, _, err := service.RenderRaw(tmpDir, "testdata/stages/schema.yaml", []string{"stage=dev", "management-cluster=mc-1", "konfiguration=konfiguration-1"})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// 3 layers with 8 stages each.
+			if len(stages) != 24 {
+				t.Fatalf("Expected 24 stages, got %d", len(stages))
+			}
+
+			observed := make(map[string]Stage)
+			for _, stage := range stages {
+				observed[stage.Layer+"/"+stage.Stage] = stage
+			}
+
+			for key, expected := range tc.expectedStages {
+				if !reflect.DeepEqual(observed[key], expected) {
+					t.Errorf("Expected stage %s to be %#v, got %#v", key, expected, observed[key])
+				}
+			}
+		})
+	}
+}
@@ -54,6 +54,7 @@ type SOPSEnvConfig struct {
 
 type SOPSEnv struct {
 	cleanup    func()
+	restoreEnv func()
 	k8sClient  kubernetes.Interface
 	keysDir    string
 	keysSource string
@@ -118,7 +119,13 @@ func NewSOPSEnv(config SOPSEnvConfig) (*SOPSEnv, error) {
 	return s, nil
 }
 
+// Cleanup restores the environment variables exported by Setup and removes
+// the temporary keys directory, if any.
 func (s *SOPSEnv) Cleanup() {
+	if s.restoreEnv != nil {
+		s.restoreEnv()
+		s.restoreEnv = nil
+	}
 	if s.cleanup != nil {
 		s.cleanup()
 	}
@@ -187,9 +194,36 @@ func (s *SOPSEnv) importKeys(ctx context.Context) error {
 		return nil
 	}
 
-	ageKeysMap := map[string][]byte{}
+	data := make([]map[string][]byte, 0, len(secrets.Items))
 	for _, secret := range secrets.Items {
-		for k, v := range secret.Data {
+		data = append(data, secret.Data)
+	}
+
+	return s.importKeyData(ctx, data...)
+}
+
+// ImportKeys imports the given PGP and AGE keys into the keys directory, e.g.
+// keys handed over by a client instead of read from Kubernetes Secrets. Keys
+// are named like the data of the Secrets, PGP keys with the `.asc` extension
+// and AGE keys with the `.agekey` extension, others are ignored. It must be
+// called after Setup, on a fresh keys directory.
+func (s *SOPSEnv) ImportKeys(ctx context.Context, keys map[string][]byte) error {
+	if s.keysDir == "" {
+		return &InvalidConfigError{message: "importing keys requires a keys directory"}
+	}
+
+	if _, err := os.Stat(s.keysDir); os.IsNotExist(err) {
+		return &NotFoundError{message: "specified keychains directory does not exist"}
+	}
+
+	return s.importKeyData(ctx, keys)
+}
+
+// importKeyData imports the keys of the data of one or more Secrets.
+func (s *SOPSEnv) importKeyData(ctx context.Context, data ...map[string][]byte) error {
+	ageKeysMap := map[string][]byte{}
+	for _, keys := range data {
+		for k, v := range keys {
 			switch ext := filepath.Ext(k); ext {
 			case secretPGPExt:
 				args := []string{
@@ -210,12 +244,7 @@ func (s *SOPSEnv) importKeys(ctx context.Context) error {
 		}
 	}
 
-	err = s.writeKeysTxt(ctx, ageKeysMap)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.writeKeysTxt(ctx, ageKeysMap)
 }
 
 // RunGPGCmd runs GPG binary with given args and input.
@@ -230,10 +259,29 @@ func (s *SOPSEnv) runGPGCmd(ctx context.Context, stdin io.Reader, args []string)
 }
 
 // setEnv exports GnuPGP and AGE environment variables telling
-// where to find private keys
+// where to find private keys. Their previous values are restored on Cleanup.
 func (s *SOPSEnv) setEnv() error {
 	var err error
 
+	previous := map[string]*string{}
+	for _, name := range []string{gnuPGHomeVar, ageKeyFileVar} {
+		if value, found := os.LookupEnv(name); found {
+			previous[name] = &value
+		} else {
+			previous[name] = nil
+		}
+	}
+
+	s.restoreEnv = func() {
+		for name, value := range previous {
+			if value != nil {
+				_ = os.Setenv(name, *value)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}
+	}
+
 	err = os.Setenv(gnuPGHomeVar, s.keysDir)
 	if err != nil {
 		return err
@@ -297,6 +297,60 @@ func TestImportKeys(t *testing.T) {
 	}
 }
 
+func TestImportKeysFromClient(t *testing.T) {
+	err := testutils.UntarFile("testdata/keys", "keys.tgz")
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	t.Setenv(ageKeyFileVar, "/previous/keys.txt")
+
+	keysDir := t.TempDir()
+
+	se, err := NewSOPSEnv(SOPSEnvConfig{
+		KeysDir:    keysDir,
+		KeysSource: key.KeysSourceLocal,
+		Logger:     logr.Discard(),
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	err = se.Setup(context.TODO())
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	ageKey := testutils.GetFile("testdata/keys/age1q3ed8z5e25t5a2vmzvzsyc9kevd68ukvuvajex0jwhewupat95zsdjmmrw.private")
+
+	err = se.ImportKeys(context.TODO(), map[string][]byte{
+		"key.agekey": ageKey,
+		"password":   []byte(`security`),
+	})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	keysTxt, err := os.ReadFile(os.Getenv(ageKeyFileVar))
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(keysTxt, ageKey) {
+		t.Fatalf("want matching files \n %s", cmp.Diff(keysTxt, ageKey))
+	}
+
+	se.Cleanup()
+
+	if os.Getenv(ageKeyFileVar) != "/previous/keys.txt" {
+		t.Fatalf("want %s=%s restored, got %s", ageKeyFileVar, "/previous/keys.txt", os.Getenv(ageKeyFileVar))
+	}
+
+	if _, found := os.LookupEnv(gnuPGHomeVar); found && os.Getenv(gnuPGHomeVar) == keysDir {
+		t.Fatalf("want %s restored, got %s", gnuPGHomeVar, keysDir)
+	}
+}
+
 func tmpDirName(suffix string) string {
 	path := filepath.Join(os.TempDir(), konfigureTmpDirName+suffix)
 	return path
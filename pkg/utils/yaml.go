@@ -3,6 +3,7 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"sort"
 
 	k8sIoYaml "sigs.k8s.io/yaml"
@@ -66,13 +67,59 @@ func sortYAMLKeysNode(node *yaml3.Node) {
 	node.Content = sortedContent
 }
 
-func PrettyPrint(in interface{}) error {
+// RedactedValue replaces the values of redacted YAML documents.
+const RedactedValue = "<redacted>"
+
+// RedactYAMLValues replaces all scalar values of the YAML document with
+// RedactedValue, keeping its keys and structure intact.
+func RedactYAMLValues(yamlString string) (string, error) {
+	if yamlString == "" {
+		return yamlString, nil
+	}
+
+	n := new(yaml3.Node)
+	err := yaml3.Unmarshal([]byte(yamlString), n)
+	if err != nil {
+		return "", err
+	}
+	redactYAMLValuesNode(n)
+	buf := new(bytes.Buffer)
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(2)
+	err = enc.Encode(n)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func redactYAMLValuesNode(node *yaml3.Node) {
+	switch node.Kind {
+	case yaml3.DocumentNode, yaml3.SequenceNode:
+		for _, n := range node.Content {
+			redactYAMLValuesNode(n)
+		}
+	case yaml3.MappingNode:
+		for index := 1; index < len(node.Content); index = index + 2 {
+			redactYAMLValuesNode(node.Content[index])
+		}
+	case yaml3.ScalarNode:
+		node.Value = RedactedValue
+		node.Tag = "!!str"
+		node.Style = 0
+	case yaml3.AliasNode:
+		// Aliases point to redacted anchors, nothing to do.
+	}
+}
+
+// PrettyPrint writes the object to w as a YAML document, e.g. a ConfigMap
+// in the format the render command prints it.
+func PrettyPrint(w io.Writer, in interface{}) error {
 	out, err := k8sIoYaml.Marshal(in)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("---")
-	fmt.Printf("%s\n", out)
-	return nil
+	_, err = fmt.Fprintf(w, "---\n%s\n", out)
+	return err
 }
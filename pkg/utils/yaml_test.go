@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func Test_sortYAMLKeys_null(t *testing.T) {
 	t.Parallel()
@@ -14,3 +17,49 @@ func Test_sortYAMLKeys_null(t *testing.T) {
 		t.Fatalf("out = %v, want %v", out, "")
 	}
 }
+
+func Test_RedactYAMLValues(t *testing.T) {
+	t.Parallel()
+
+	in := `a:
+  b: secret
+  c:
+    - 1
+    - d: true
+e: |
+  multi
+  line
+`
+	expected := `a:
+  b: <redacted>
+  c:
+    - <redacted>
+    - d: <redacted>
+e: <redacted>
+`
+
+	out, err := RedactYAMLValues(in)
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", err, nil)
+	}
+
+	if out != expected {
+		t.Fatalf("out = %v, want %v", out, expected)
+	}
+}
+
+func Test_PrettyPrint(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	err := PrettyPrint(&out, map[string]interface{}{"b": 1, "a": "x"})
+	if err != nil {
+		t.Fatalf("err = %#q, want %#v", err, nil)
+	}
+
+	expected := "---\na: x\nb: 1\n\n"
+	if out.String() != expected {
+		t.Fatalf("out = %q, want %q", out.String(), expected)
+	}
+}